@@ -6,8 +6,12 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/chaos-synthesis/mattermost-plugin-retention/server/command"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/config"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store"
 	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store/kvstore"
 	"github.com/gorilla/mux"
 	"github.com/mattermost/mattermost/server/public/model"
@@ -25,6 +29,16 @@ func (p *Plugin) initRouter() *mux.Router {
 
 	apiRouter.HandleFunc("/actions/settings", p.ShowSettings)
 	apiRouter.HandleFunc("/settings", p.SaveSettings)
+	apiRouter.HandleFunc("/actions/channel-settings", p.ShowChannelSettings)
+	apiRouter.HandleFunc("/channel-settings", p.SaveChannelSettings)
+	apiRouter.HandleFunc("/actions/team-settings", p.ShowTeamSettings)
+	apiRouter.HandleFunc("/team-settings", p.SaveTeamSettings)
+
+	adminRouter := apiRouter.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(p.SystemAdminRequired)
+	adminRouter.HandleFunc("/dry-run", p.GetDryRunReport).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/history", p.GetRunHistory).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/schedule", p.GetSchedule).Methods(http.MethodGet)
 
 	return router
 }
@@ -47,6 +61,94 @@ func (p *Plugin) MattermostAuthorizationRequired(next http.Handler) http.Handler
 	})
 }
 
+// SystemAdminRequired restricts the wrapped handlers to system admins.
+func (p *Plugin) SystemAdminRequired(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Header.Get("Mattermost-User-ID")
+		if !p.client.User.HasPermissionTo(userID, model.PermissionManageSystem) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GetDryRunReport returns the report of the last dry retention run.
+func (p *Plugin) GetDryRunReport(w http.ResponseWriter, r *http.Request) {
+	report, err := p.kvStore.GetDryRunReport()
+	if err != nil {
+		p.API.LogError("Failed to get dry run report", "err", err.Error())
+		http.Error(w, "Failed to get dry run report", http.StatusInternalServerError)
+		return
+	}
+	if report == nil {
+		http.Error(w, "No dry run has completed yet", http.StatusNotFound)
+		return
+	}
+
+	p.writeJSON(w, report)
+}
+
+// GetRunHistory returns the last retention runs, newest first.
+func (p *Plugin) GetRunHistory(w http.ResponseWriter, r *http.Request) {
+	history, err := p.kvStore.GetRunHistory()
+	if err != nil {
+		p.API.LogError("Failed to get run history", "err", err.Error())
+		http.Error(w, "Failed to get run history", http.StatusInternalServerError)
+		return
+	}
+	if history == nil {
+		history = []kvstore.RunStatus{}
+	}
+
+	p.writeJSON(w, history)
+}
+
+// upcomingRuns lists the next scheduled runs in the time zone of the schedule and in the requesting user's.
+type upcomingRuns struct {
+	TimeZone     string
+	UserTimeZone string
+	Runs         []upcomingRun
+}
+
+type upcomingRun struct {
+	Time     time.Time
+	UserTime time.Time
+}
+
+// GetSchedule returns the next scheduled runs; the count query parameter sets how many.
+func (p *Plugin) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	count := command.DefaultUpcomingRuns
+	if countStr := r.URL.Query().Get("count"); countStr != "" {
+		n, err := config.ParseInt(countStr, 1, command.MaxUpcomingRuns)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("count must be between 1 and %d", command.MaxUpcomingRuns), http.StatusBadRequest)
+			return
+		}
+		count = n
+	}
+
+	runs, err := p.GetUpcomingRuns(count)
+	if err != nil {
+		p.API.LogError("Failed to compute the schedule", "err", err.Error())
+		http.Error(w, "Failed to compute the schedule", http.StatusInternalServerError)
+		return
+	}
+
+	userLoc := command.UserLocation(p.client, r.Header.Get("Mattermost-User-ID"))
+	response := upcomingRuns{Runs: []upcomingRun{}}
+	for _, run := range runs {
+		response.Runs = append(response.Runs, upcomingRun{Time: run, UserTime: run.In(userLoc)})
+	}
+	if len(runs) > 0 {
+		response.TimeZone = command.ZoneName(runs[0])
+		response.UserTimeZone = command.ZoneName(runs[0].In(userLoc))
+	}
+
+	p.writeJSON(w, response)
+}
+
 func (p *Plugin) ShowSettings(w http.ResponseWriter, r *http.Request) {
 	var payload model.PostActionIntegrationRequest
 	err := json.NewDecoder(r.Body).Decode(&payload)
@@ -73,6 +175,56 @@ func (p *Plugin) ShowSettings(w http.ResponseWriter, r *http.Request) {
 	if userPrefs.PostAgeInDays > 0. {
 		ageInDays = userPrefs.PostAgeInDays
 	}
+	ageInDays = p.getConfiguration().GetPostAgeBounds().Clamp(ageInDays)
+
+	ageInHours := ""
+	if userPrefs.PostAgeInHours > 0 {
+		ageInHours = interfaceToString(userPrefs.PostAgeInHours)
+	}
+
+	elements := append(retentionDialogElements(userPrefs.Enabled, ageInDays, userPrefs.ThreadMode, userPrefs.AgeBasis), model.DialogElement{
+		DisplayName: "Age in hours",
+		Name:        "age_in_hours",
+		Type:        "text",
+		Optional:    true,
+		HelpText:    "Age in hours for a post to be considered stale, for short-lived posts. Overrides the age in days when set.",
+		MaxLength:   10,
+		Default:     ageInHours,
+	}, model.DialogElement{
+		DisplayName: "Keep pinned posts",
+		Name:        "keep_pinned",
+		Type:        "bool",
+		Optional:    true,
+		HelpText:    "Never delete posts pinned to a channel.",
+		Default:     interfaceToString(!userPrefs.DeletePinned),
+	}, model.DialogElement{
+		DisplayName: "Keep saved posts",
+		Name:        "keep_saved",
+		Type:        "bool",
+		Optional:    true,
+		HelpText:    "Never delete posts saved by you or anyone else.",
+		Default:     interfaceToString(!userPrefs.DeleteSaved),
+	})
+	for _, option := range command.ChannelTypeOptions {
+		elements = append(elements, model.DialogElement{
+			DisplayName: option.DisplayName,
+			Name:        channelTypeElementName(option.Type),
+			Type:        "bool",
+			Optional:    true,
+			HelpText:    fmt.Sprintf("Delete your stale posts in %s.", strings.ToLower(option.DisplayName)),
+			Default:     interfaceToString(userPrefs.IncludesChannelType(option.Type)),
+		})
+	}
+	elements = append(elements, model.DialogElement{
+		DisplayName: "Never purge my posts in",
+		Name:        "excluded_channels",
+		Type:        "select",
+		DataSource:  "channels",
+		MultiSelect: true,
+		Optional:    true,
+		HelpText:    "Your posts in these channels are kept. Policies set by channel admins still apply.",
+		Default:     strings.Join(userPrefs.ExcludedChannelIDs, ","),
+	})
 
 	dialog := model.OpenDialogRequest{
 		TriggerId: payload.TriggerId,
@@ -83,22 +235,7 @@ func (p *Plugin) ShowSettings(w http.ResponseWriter, r *http.Request) {
 			IconURL:     "http://www.mattermost.org/wp-content/uploads/2016/04/icon.png",
 			SubmitLabel: "Save",
 			State:       payload.PostId,
-			Elements: []model.DialogElement{{
-				DisplayName: "Enabled",
-				Name:        "enabled",
-				Type:        "bool",
-				Optional:    true,
-				HelpText:    "Enable or disable the post retention policy.",
-				Default:     interfaceToString(userPrefs.Enabled),
-			}, {
-				DisplayName: "Age in days",
-				Name:        "age_in_days",
-				Type:        "text",
-				HelpText:    "Age in days for a post to be considered stale and deleted.",
-				MinLength:   1,
-				MaxLength:   10,
-				Default:     interfaceToString(ageInDays),
-			}},
+			Elements:    elements,
 		},
 	}
 
@@ -128,30 +265,57 @@ func (p *Plugin) SaveSettings(w http.ResponseWriter, r *http.Request) {
 		}
 	}(r.Body)
 
-	enabledValue := false
-	ageInDaysValue := 0.
-	if !request.Cancelled {
-		if enabled, ok := request.Submission["enabled"].(bool); ok {
-			enabledValue = enabled
-		}
+	bounds := p.getConfiguration().GetPostAgeBounds()
+	submission, dialogErrors := parseRetentionSubmission(&request, bounds)
+	if len(dialogErrors) > 0 {
+		p.writeJSON(w, &model.SubmitDialogResponse{Errors: dialogErrors})
+		return
+	}
 
-		if numberStr, ok := request.Submission["age_in_days"].(string); ok {
-			number, parseErr := strconv.ParseFloat(numberStr, 64)
-			if number <= 0 || parseErr != nil {
-				response := &model.SubmitDialogResponse{
-					Errors: map[string]string{"age_in_days": "This must be integer greater than 0"},
-				}
-				p.writeJSON(w, response)
-				return
-			}
-			ageInDaysValue = number
+	ageInHours, dialogErrors := parseAgeInHours(&request, bounds)
+	if len(dialogErrors) > 0 {
+		p.writeJSON(w, &model.SubmitDialogResponse{Errors: dialogErrors})
+		return
+	}
+
+	// missing values keep the posts, the safe default
+	keepPinned, ok := request.Submission["keep_pinned"].(bool)
+	if !ok {
+		keepPinned = true
+	}
+	keepSaved, ok := request.Submission["keep_saved"].(bool)
+	if !ok {
+		keepSaved = true
+	}
+
+	// all types selected is stored as no filter, so the policy also covers channel types added later
+	var channelTypes []model.ChannelType
+	for _, option := range command.ChannelTypeOptions {
+		if selected, _ := request.Submission[channelTypeElementName(option.Type)].(bool); selected {
+			channelTypes = append(channelTypes, option.Type)
 		}
 	}
+	if submission.Enabled && len(channelTypes) == 0 {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Errors: map[string]string{channelTypeElementName(command.ChannelTypeOptions[0].Type): "Select at least one channel type"},
+		})
+		return
+	}
+	if len(channelTypes) == len(command.ChannelTypeOptions) {
+		channelTypes = nil
+	}
 
 	userSettings := kvstore.UserSettings{
-		UserID:        request.UserId,
-		Enabled:       enabledValue,
-		PostAgeInDays: ageInDaysValue,
+		UserID:             request.UserId,
+		Enabled:            submission.Enabled,
+		PostAgeInDays:      submission.PostAgeInDays,
+		PostAgeInHours:     ageInHours,
+		ThreadMode:         submission.ThreadMode,
+		AgeBasis:           submission.AgeBasis,
+		DeletePinned:       !keepPinned,
+		DeleteSaved:        !keepSaved,
+		ChannelTypes:       channelTypes,
+		ExcludedChannelIDs: submissionToStrings(request.Submission["excluded_channels"]),
 	}
 
 	toastMessage := "Your settings have been saved successfully!"
@@ -161,7 +325,232 @@ func (p *Plugin) SaveSettings(w http.ResponseWriter, r *http.Request) {
 		toastMessage = "Failed to save your settings. Please contact administrator."
 	}
 
-	post := command.CreateStateMessagePost(userSettings, p.GetBundleURL(), toastMessage)
+	keepReaction, err := p.GetKeepReaction(request.UserId)
+	if err != nil {
+		p.API.LogWarn("Failed to count kept posts", "err", err.Error())
+	}
+
+	post := command.CreateStateMessagePost(userSettings, keepReaction, p.GetBundleURL(), toastMessage)
+	post.Id = request.State
+	post.ChannelId = request.ChannelId
+
+	p.API.UpdateEphemeralPost(request.UserId, post)
+
+	resp := &model.PostActionIntegrationResponse{}
+	p.writeJSON(w, resp)
+}
+
+func (p *Plugin) ShowChannelSettings(w http.ResponseWriter, r *http.Request) {
+	var payload model.PostActionIntegrationRequest
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		http.Error(w, "Failed to decode PostActionIntegrationRequest", http.StatusBadRequest)
+		return
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			p.API.LogError("Failed to close request body", "err", err)
+		}
+	}(r.Body)
+
+	userID := r.Header.Get("Mattermost-User-ID")
+	if !command.CanManageChannelPolicy(p.client, userID, payload.ChannelId) {
+		http.Error(w, "Not authorized", http.StatusForbidden)
+		return
+	}
+
+	channelPrefs, err := p.kvStore.GetChannelSettings(payload.ChannelId)
+	if err != nil {
+		p.API.LogError("Failed to get channel settings", "err", err.Error())
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	ageInDays := 365.
+	if channelPrefs.PostAgeInDays > 0. {
+		ageInDays = channelPrefs.PostAgeInDays
+	}
+	ageInDays = p.getConfiguration().GetPostAgeBounds().Clamp(ageInDays)
+
+	dialog := model.OpenDialogRequest{
+		TriggerId: payload.TriggerId,
+		URL:       p.GetBundleURL() + "/api/v1/channel-settings", // Endpoint for handling submission
+		Dialog: model.Dialog{
+			CallbackId:       "channelsettingscallbackid",
+			Title:            "Channel Post Retention Settings",
+			IntroductionText: "The policy applies to the posts of **all** channel members. Pinned and saved posts are always kept.",
+			IconURL:          "http://www.mattermost.org/wp-content/uploads/2016/04/icon.png",
+			SubmitLabel:      "Save",
+			State:            payload.PostId,
+			Elements:         retentionDialogElements(channelPrefs.Enabled, ageInDays, channelPrefs.ThreadMode, channelPrefs.AgeBasis),
+		},
+	}
+
+	if err := p.API.OpenInteractiveDialog(dialog); err != nil {
+		p.API.LogError("Failed to open interactive dialog", "err", err.Error())
+		http.Error(w, "Failed to open dialog", http.StatusInternalServerError)
+		return
+	}
+
+	response := &model.CommandResponse{}
+
+	p.writeJSON(w, response)
+}
+
+func (p *Plugin) SaveChannelSettings(w http.ResponseWriter, r *http.Request) {
+	var request model.SubmitDialogRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		p.API.LogError("Failed to decode SubmitDialogRequest", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			p.API.LogError("Failed to close request body", "err", err)
+		}
+	}(r.Body)
+
+	if !command.CanManageChannelPolicy(p.client, request.UserId, request.ChannelId) {
+		p.writeJSON(w, &model.SubmitDialogResponse{Error: "Only channel admins can manage the channel retention policy."})
+		return
+	}
+
+	submission, dialogErrors := parseRetentionSubmission(&request, p.getConfiguration().GetPostAgeBounds())
+	if len(dialogErrors) > 0 {
+		p.writeJSON(w, &model.SubmitDialogResponse{Errors: dialogErrors})
+		return
+	}
+
+	channelSettings := kvstore.ChannelSettings{
+		ChannelID:     request.ChannelId,
+		Enabled:       submission.Enabled,
+		PostAgeInDays: submission.PostAgeInDays,
+		ThreadMode:    submission.ThreadMode,
+		AgeBasis:      submission.AgeBasis,
+		UpdatedBy:     request.UserId,
+	}
+
+	toastMessage := "Channel settings have been saved successfully!"
+	err = p.kvStore.SetChannelSettings(request.ChannelId, &channelSettings)
+	if err != nil {
+		p.API.LogError("Failed to set channel settings", "err", err.Error())
+		toastMessage = "Failed to save channel settings. Please contact administrator."
+	}
+
+	post := command.CreateChannelStateMessagePost(channelSettings, p.GetBundleURL(), toastMessage)
+	post.Id = request.State
+	post.ChannelId = request.ChannelId
+
+	p.API.UpdateEphemeralPost(request.UserId, post)
+
+	resp := &model.PostActionIntegrationResponse{}
+	p.writeJSON(w, resp)
+}
+
+func (p *Plugin) ShowTeamSettings(w http.ResponseWriter, r *http.Request) {
+	var payload model.PostActionIntegrationRequest
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		http.Error(w, "Failed to decode PostActionIntegrationRequest", http.StatusBadRequest)
+		return
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			p.API.LogError("Failed to close request body", "err", err)
+		}
+	}(r.Body)
+
+	userID := r.Header.Get("Mattermost-User-ID")
+	if !command.CanManageTeamPolicy(p.client, userID, payload.TeamId) {
+		http.Error(w, "Not authorized", http.StatusForbidden)
+		return
+	}
+
+	teamPrefs, err := p.kvStore.GetTeamSettings(payload.TeamId)
+	if err != nil {
+		p.API.LogError("Failed to get team settings", "err", err.Error())
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	ageInDays := 365.
+	if teamPrefs.PostAgeInDays > 0. {
+		ageInDays = teamPrefs.PostAgeInDays
+	}
+	ageInDays = p.getConfiguration().GetPostAgeBounds().Clamp(ageInDays)
+
+	dialog := model.OpenDialogRequest{
+		TriggerId: payload.TriggerId,
+		URL:       p.GetBundleURL() + "/api/v1/team-settings", // Endpoint for handling submission
+		Dialog: model.Dialog{
+			CallbackId:       "teamsettingscallbackid",
+			Title:            "Team Post Retention Settings",
+			IntroductionText: "The policy applies to the posts of **all** team members in the team channels, unless a member has a stricter personal policy. Pinned and saved posts are always kept.",
+			IconURL:          "http://www.mattermost.org/wp-content/uploads/2016/04/icon.png",
+			SubmitLabel:      "Save",
+			State:            payload.PostId,
+			Elements:         retentionDialogElements(teamPrefs.Enabled, ageInDays, teamPrefs.ThreadMode, teamPrefs.AgeBasis),
+		},
+	}
+
+	if err := p.API.OpenInteractiveDialog(dialog); err != nil {
+		p.API.LogError("Failed to open interactive dialog", "err", err.Error())
+		http.Error(w, "Failed to open dialog", http.StatusInternalServerError)
+		return
+	}
+
+	response := &model.CommandResponse{}
+
+	p.writeJSON(w, response)
+}
+
+func (p *Plugin) SaveTeamSettings(w http.ResponseWriter, r *http.Request) {
+	var request model.SubmitDialogRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		p.API.LogError("Failed to decode SubmitDialogRequest", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			p.API.LogError("Failed to close request body", "err", err)
+		}
+	}(r.Body)
+
+	if !command.CanManageTeamPolicy(p.client, request.UserId, request.TeamId) {
+		p.writeJSON(w, &model.SubmitDialogResponse{Error: "Only team admins can manage the team retention policy."})
+		return
+	}
+
+	submission, dialogErrors := parseRetentionSubmission(&request, p.getConfiguration().GetPostAgeBounds())
+	if len(dialogErrors) > 0 {
+		p.writeJSON(w, &model.SubmitDialogResponse{Errors: dialogErrors})
+		return
+	}
+
+	teamSettings := kvstore.TeamSettings{
+		TeamID:        request.TeamId,
+		Enabled:       submission.Enabled,
+		PostAgeInDays: submission.PostAgeInDays,
+		ThreadMode:    submission.ThreadMode,
+		AgeBasis:      submission.AgeBasis,
+		UpdatedBy:     request.UserId,
+	}
+
+	toastMessage := "Team settings have been saved successfully!"
+	err = p.kvStore.SetTeamSettings(request.TeamId, &teamSettings)
+	if err != nil {
+		p.API.LogError("Failed to set team settings", "err", err.Error())
+		toastMessage = "Failed to save team settings. Please contact administrator."
+	}
+
+	post := command.CreateTeamStateMessagePost(teamSettings, p.GetBundleURL(), toastMessage)
 	post.Id = request.State
 	post.ChannelId = request.ChannelId
 
@@ -173,6 +562,119 @@ func (p *Plugin) SaveSettings(w http.ResponseWriter, r *http.Request) {
 
 // Utility functions
 
+// channelTypeElementName is the name of the user settings dialog checkbox for a channel type.
+func channelTypeElementName(channelType model.ChannelType) string {
+	return "channel_type_" + string(channelType)
+}
+
+// retentionDialogElements builds the dialog elements shared by the user, channel and team settings dialogs.
+func retentionDialogElements(enabled bool, ageInDays float64, threadMode string, ageBasis string) []model.DialogElement {
+	threadModeOptions := make([]*model.PostActionOptions, 0, len(store.ThreadModes))
+	for _, mode := range store.ThreadModes {
+		threadModeOptions = append(threadModeOptions, &model.PostActionOptions{
+			Text:  mode.DisplayName(),
+			Value: string(mode),
+		})
+	}
+
+	ageBasisOptions := make([]*model.PostActionOptions, 0, len(store.AgeBases))
+	for _, basis := range store.AgeBases {
+		ageBasisOptions = append(ageBasisOptions, &model.PostActionOptions{
+			Text:  basis.DisplayName(),
+			Value: string(basis),
+		})
+	}
+
+	return []model.DialogElement{{
+		DisplayName: "Enabled",
+		Name:        "enabled",
+		Type:        "bool",
+		Optional:    true,
+		HelpText:    "Enable or disable the post retention policy.",
+		Default:     interfaceToString(enabled),
+	}, {
+		DisplayName: "Age in days",
+		Name:        "age_in_days",
+		Type:        "text",
+		HelpText:    "Age in days for a post to be considered stale and deleted.",
+		MinLength:   1,
+		MaxLength:   10,
+		Default:     interfaceToString(ageInDays),
+	}, {
+		DisplayName: "Age measured from",
+		Name:        "age_basis",
+		Type:        "select",
+		HelpText:    "Which timestamp of a post the age is measured from. Edits, reactions and pinning update a post.",
+		Options:     ageBasisOptions,
+		Default:     string(store.AgeBasisFromString(ageBasis)),
+	}, {
+		DisplayName: "Threads",
+		Name:        "thread_mode",
+		Type:        "select",
+		HelpText:    "How posts that are part of a thread are treated.",
+		Options:     threadModeOptions,
+		Default:     string(store.ThreadModeFromString(threadMode)),
+	}}
+}
+
+// retentionSubmission holds the values of the elements built by retentionDialogElements.
+type retentionSubmission struct {
+	Enabled       bool
+	PostAgeInDays float64
+	ThreadMode    string
+	AgeBasis      string
+}
+
+// parseRetentionSubmission extracts the values of the elements built by retentionDialogElements.
+// Validation failures, including retention periods outside of the admin bounds, are returned as dialog errors keyed by element name.
+func parseRetentionSubmission(request *model.SubmitDialogRequest, bounds config.PostAgeBounds) (retentionSubmission, map[string]string) {
+	submission := retentionSubmission{}
+	if request.Cancelled {
+		return submission, nil
+	}
+
+	if enabled, ok := request.Submission["enabled"].(bool); ok {
+		submission.Enabled = enabled
+	}
+
+	if numberStr, ok := request.Submission["age_in_days"].(string); ok {
+		number, parseErr := strconv.ParseFloat(numberStr, 64)
+		if number <= 0 || parseErr != nil {
+			return retentionSubmission{}, map[string]string{"age_in_days": "This must be integer greater than 0"}
+		}
+		if !bounds.Contains(number) {
+			return retentionSubmission{}, map[string]string{"age_in_days": fmt.Sprintf("Your administrator requires the retention period to be %s", bounds)}
+		}
+		submission.PostAgeInDays = number
+	}
+
+	threadMode, _ := request.Submission["thread_mode"].(string)
+	submission.ThreadMode = string(store.ThreadModeFromString(threadMode))
+
+	ageBasis, _ := request.Submission["age_basis"].(string)
+	submission.AgeBasis = string(store.AgeBasisFromString(ageBasis))
+
+	return submission, nil
+}
+
+// parseAgeInHours extracts the optional retention period in hours of the user settings dialog, 0 when it is empty.
+// Validation failures are returned as dialog errors like in parseRetentionSubmission.
+func parseAgeInHours(request *model.SubmitDialogRequest, bounds config.PostAgeBounds) (float64, map[string]string) {
+	hoursStr, _ := request.Submission["age_in_hours"].(string)
+	if request.Cancelled || strings.TrimSpace(hoursStr) == "" {
+		return 0, nil
+	}
+
+	number, parseErr := strconv.ParseFloat(strings.TrimSpace(hoursStr), 64)
+	if number <= 0 || parseErr != nil {
+		return 0, map[string]string{"age_in_hours": "This must be a number greater than 0"}
+	}
+	if !bounds.Contains(number / 24) {
+		return 0, map[string]string{"age_in_hours": fmt.Sprintf("Your administrator requires the retention period to be %s", bounds)}
+	}
+	return number, nil
+}
+
 // writeJSON is a helper function to write a JSON response with the appropriate headers and status code.
 func (p *Plugin) writeJSON(w http.ResponseWriter, response any) {
 	w.Header().Set("Content-Type", "application/json")
@@ -194,12 +696,43 @@ func (p *Plugin) GetSiteURL() string {
 	return siteURL
 }
 
+// GetLocalModeSocketPath retrieves the local mode socket location from the plugin API configuration. It returns
+// the server default when it is not set.
+func (p *Plugin) GetLocalModeSocketPath() string {
+	socketPath := p.API.GetConfig().ServiceSettings.LocalModeSocketLocation
+	if socketPath == nil || *socketPath == "" {
+		return model.LocalModeSocketPath
+	}
+	return *socketPath
+}
+
 // GetBundleURL constructs the URL to the plugin's bundle based on the SiteURL and plugin ID.
 // It returns an empty string if the SiteURL is not set.
 func (p *Plugin) GetBundleURL() string {
 	return fmt.Sprintf("%s/plugins/%s", p.GetSiteURL(), manifest.Id)
 }
 
+// submissionToStrings converts the value of a multiselect dialog element, submitted either as a list or as
+// a comma-separated string, into a list of non-empty strings.
+func submissionToStrings(value any) []string {
+	var values []string
+	switch v := value.(type) {
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				values = append(values, s)
+			}
+		}
+	case string:
+		for item := range strings.SplitSeq(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
 // interfaceToString converts an interface{} value to its string representation.
 // It handles common types such as string, float64, and bool, and falls back to using fmt.Sprintf for other types.
 func interfaceToString(value interface{}) string {
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/config"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store"
+)
+
+func TestParseRetentionSubmission(t *testing.T) {
+	bounds := config.PostAgeBounds{Min: 1, Max: 365}
+	const outOfRange = "Your administrator requires the retention period to be between 1 and 365 days"
+	const notPositive = "This must be integer greater than 0"
+
+	for _, tc := range []struct {
+		name       string
+		bounds     config.PostAgeBounds
+		submission map[string]any
+		cancelled  bool
+		expected   retentionSubmission
+		errors     map[string]string
+	}{
+		{
+			name:       "days",
+			bounds:     bounds,
+			submission: map[string]any{"enabled": true, "age_in_days": "30"},
+			expected:   withDefaultRules(retentionSubmission{Enabled: true, PostAgeInDays: 30}),
+		},
+		{
+			name:       "fractional days",
+			bounds:     bounds,
+			submission: map[string]any{"enabled": true, "age_in_days": "1.5"},
+			expected:   withDefaultRules(retentionSubmission{Enabled: true, PostAgeInDays: 1.5}),
+		},
+		{
+			name:       "bounds included",
+			bounds:     bounds,
+			submission: map[string]any{"age_in_days": "365"},
+			expected:   withDefaultRules(retentionSubmission{PostAgeInDays: 365}),
+		},
+		{
+			name:       "thread mode and age basis",
+			bounds:     bounds,
+			submission: map[string]any{"age_in_days": "7", "thread_mode": "whole_thread", "age_basis": "create_at"},
+			expected:   retentionSubmission{PostAgeInDays: 7, ThreadMode: string(store.ThreadModeWholeThread), AgeBasis: string(store.AgeBasisCreateAt)},
+		},
+		{
+			name:       "no bounds",
+			submission: map[string]any{"age_in_days": "0.01"},
+			expected:   withDefaultRules(retentionSubmission{PostAgeInDays: 0.01}),
+		},
+		{
+			name:       "zero",
+			bounds:     bounds,
+			submission: map[string]any{"age_in_days": "0"},
+			errors:     map[string]string{"age_in_days": notPositive},
+		},
+		{
+			name:       "negative",
+			bounds:     bounds,
+			submission: map[string]any{"age_in_days": "-3"},
+			errors:     map[string]string{"age_in_days": notPositive},
+		},
+		{
+			name:       "not a number",
+			bounds:     bounds,
+			submission: map[string]any{"age_in_days": "a week"},
+			errors:     map[string]string{"age_in_days": notPositive},
+		},
+		{
+			name:       "below the minimum",
+			bounds:     bounds,
+			submission: map[string]any{"age_in_days": "0.5"},
+			errors:     map[string]string{"age_in_days": outOfRange},
+		},
+		{
+			name:       "above the maximum",
+			bounds:     bounds,
+			submission: map[string]any{"age_in_days": "400"},
+			errors:     map[string]string{"age_in_days": outOfRange},
+		},
+		{
+			name:       "minimum only",
+			bounds:     config.PostAgeBounds{Min: 7},
+			submission: map[string]any{"age_in_days": "3"},
+			errors:     map[string]string{"age_in_days": "Your administrator requires the retention period to be at least 7 days"},
+		},
+		{
+			name:       "maximum only",
+			bounds:     config.PostAgeBounds{Max: 30},
+			submission: map[string]any{"age_in_days": "31"},
+			errors:     map[string]string{"age_in_days": "Your administrator requires the retention period to be at most 30 days"},
+		},
+		{
+			name:       "cancelled",
+			bounds:     bounds,
+			submission: map[string]any{"age_in_days": "0"},
+			cancelled:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			request := &model.SubmitDialogRequest{Submission: tc.submission, Cancelled: tc.cancelled}
+			submission, errors := parseRetentionSubmission(request, tc.bounds)
+			assert.Equal(t, tc.errors, errors)
+			assert.Equal(t, tc.expected, submission)
+		})
+	}
+}
+
+// withDefaultRules sets the thread mode and age basis a submission without them resolves to.
+func withDefaultRules(submission retentionSubmission) retentionSubmission {
+	submission.ThreadMode = string(store.DefaultThreadMode)
+	submission.AgeBasis = string(store.DefaultAgeBasis)
+	return submission
+}
+
+func TestParseAgeInHours(t *testing.T) {
+	bounds := config.PostAgeBounds{Min: 0.25, Max: 2}
+	const outOfRange = "Your administrator requires the retention period to be between 6 hours and 2 days"
+	const notPositive = "This must be a number greater than 0"
+
+	for _, tc := range []struct {
+		name   string
+		hours  any
+		hoursN float64
+		errors map[string]string
+	}{
+		{name: "missing", hours: nil},
+		{name: "empty", hours: "  "},
+		{name: "hours", hours: "12", hoursN: 12},
+		{name: "spaces", hours: " 6 ", hoursN: 6},
+		{name: "fractional hours", hours: "7.5", hoursN: 7.5},
+		{name: "zero", hours: "0", errors: map[string]string{"age_in_hours": notPositive}},
+		{name: "negative", hours: "-12", errors: map[string]string{"age_in_hours": notPositive}},
+		{name: "not a number", hours: "12h", errors: map[string]string{"age_in_hours": notPositive}},
+		{name: "below the minimum", hours: "5", errors: map[string]string{"age_in_hours": outOfRange}},
+		{name: "above the maximum", hours: "49", errors: map[string]string{"age_in_hours": outOfRange}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			request := &model.SubmitDialogRequest{Submission: map[string]any{}}
+			if tc.hours != nil {
+				request.Submission["age_in_hours"] = tc.hours
+			}
+
+			hours, errors := parseAgeInHours(request, bounds)
+			assert.Equal(t, tc.errors, errors)
+			assert.Equal(t, tc.hoursN, hours)
+		})
+	}
+}
@@ -2,11 +2,17 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
-	"github.com/chaos-synthesis/mattermost-plugin-retention/server/mmctl/commands"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/config"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/deleter"
 	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store/kvstore"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/throttle"
+	"github.com/mattermost/mattermost/server/public/model"
 )
 
 type Reason string
@@ -17,25 +23,172 @@ const (
 	ReasonError     Reason = "error"
 )
 
+// record accounts the outcome of deleting a batch of posts. It returns the last deletion error, if any.
+func (r *ArchiverResults) record(posts []*model.Post, deleteResults []deleter.Result) error {
+	authors := make(map[string]string, len(posts))
+	for _, post := range posts {
+		authors[post.Id] = post.UserId
+	}
+
+	var lastErr error
+	for _, result := range deleteResults {
+		userID := authors[result.PostID]
+		userCounts, ok := r.PerUser[userID]
+		if !ok {
+			userCounts = &PostCounts{}
+			r.PerUser[userID] = userCounts
+		}
+
+		switch {
+		case result.Err == nil:
+			r.PostsDeleted++
+			userCounts.Deleted++
+		case errors.Is(result.Err, context.Canceled) || errors.Is(result.Err, context.DeadlineExceeded):
+			r.PostsSkipped++
+			userCounts.Skipped++
+		default:
+			r.PostsFailed++
+			userCounts.Failed++
+			if len(r.FailedPosts) < maxFailedPosts {
+				r.FailedPosts = append(r.FailedPosts, FailedPost{PostID: result.PostID, UserID: userID, Error: result.Err.Error()})
+			}
+			lastErr = result.Err
+		}
+	}
+	return lastErr
+}
+
+// newPostDeleter creates the deletion backend selected in the configuration.
+func (p *Plugin) newPostDeleter(cfg *config.Configuration) (deleter.PostDeleter, error) {
+	backend, err := config.DeletionBackendFromString(cfg.DeletionBackend)
+	if err != nil {
+		return nil, err
+	}
+
+	switch backend {
+	case config.DeletionBackendPluginAPI:
+		return deleter.NewPluginAPIDeleter(p.client), nil
+	case config.DeletionBackendHTTP:
+		apiURL := cfg.DeletionAPIURL
+		if apiURL == "" {
+			apiURL = p.GetSiteURL()
+		}
+		return deleter.NewHTTPDeleter(apiURL, cfg.DeletionAPIToken)
+	default:
+		return deleter.NewMmctlDeleter(p.GetLocalModeSocketPath())
+	}
+}
+
+// teamMembersPageSize is the number of team members fetched at once when applying team policies.
+const teamMembersPageSize = 200
+
 type ArchiverOpts struct {
+	// BatchSize is the initial batch size, adapted by Throttle during the run.
 	BatchSize   int
 	MaxWarnings int
+	// Throttle paces the batches; one starting at BatchSize is created when nil.
+	Throttle *throttle.Controller
+	// Workers is the number of users processed concurrently; their deletions share the Deleter's rate limit.
+	Workers int
+	// PostAgeBounds clamps the retention period of every policy, so out-of-range stored values are never used as is.
+	PostAgeBounds config.PostAgeBounds
+	// KeepReactionEmoji protects posts carrying this reaction from their author or an admin; empty disables it.
+	KeepReactionEmoji string
+	// Deleter deletes the stale posts; not used for dry runs.
+	Deleter deleter.PostDeleter
+	// DryRun walks all policies and batches without deleting anything, collecting a report of the stale posts instead.
+	DryRun bool
 
 	ProgressFn func(results *ArchiverResults) // optional callback to receive results per batch
+	// Resume continues an interrupted run from its checkpoint; nil starts a new run. Ignored for dry runs.
+	Resume *kvstore.RunCheckpoint
+	// RunID identifies a new run; one is generated when empty. Resumed runs keep the ID of their checkpoint.
+	RunID string
+}
+
+// maxFailedPosts is the number of failed posts whose errors are kept in ArchiverResults.
+const maxFailedPosts = 20
+
+// FailedPost is a post that could not be deleted.
+type FailedPost struct {
+	PostID string
+	UserID string
+	Error  string
+}
+
+// PostCounts are deletion outcomes counted per post.
+type PostCounts struct {
+	Deleted int
+	Failed  int
+	// Skipped posts were not attempted because the run was cancelled.
+	Skipped int
 }
 
 type ArchiverResults struct {
+	RunID        string
 	PostsDeleted int
+	PostsFailed  int
+	PostsSkipped int
+	// FailedPosts holds the first maxFailedPosts failures.
+	FailedPosts []FailedPost
+	// PerUser counts the outcomes per post author.
+	PerUser map[string]*PostCounts
+	// DryRunReport lists the posts that would have been deleted; only set for dry runs.
+	DryRunReport *kvstore.DryRunReport
 	ExitReason   Reason
-	Duration     time.Duration
-	start        time.Time
+	// ExitDetail explains the exit reason, such as "window closed" for a cancelled run.
+	ExitDetail string
+	Duration   time.Duration
+	start      time.Time
+
+	// mux guards the results and positions while workers run.
+	mux sync.Mutex
+	// startedAt is when the run first started, before any interruption.
+	startedAt int64
+	// usersFrom is the user the active users are rotated to start at.
+	usersFrom string
+	// positions are the scopes being processed, resume the position an interrupted run is continued from.
+	positions map[*runPosition]struct{}
+	resume    *kvstore.RunCheckpoint
+}
+
+// newArchiverResults starts the results of a new run, or of an interrupted run continued from resume.
+func newArchiverResults(resume *kvstore.RunCheckpoint) *ArchiverResults {
+	results := &ArchiverResults{
+		RunID:      model.NewId(),
+		PerUser:    map[string]*PostCounts{},
+		ExitReason: ReasonDone,
+		start:      time.Now(),
+		startedAt:  model.GetMillis(),
+		positions:  map[*runPosition]struct{}{},
+	}
+
+	if resume != nil {
+		results.RunID = resume.RunID
+		results.startedAt = resume.StartedAt
+		results.usersFrom = resume.UsersFrom
+		results.PostsDeleted = resume.PostsDeleted
+		results.PostsFailed = resume.PostsFailed
+		results.PostsSkipped = resume.PostsSkipped
+		results.resume = resume
+	}
+	return results
+}
+
+// setExitReason records why the run stopped; safe to call from workers.
+func (r *ArchiverResults) setExitReason(reason Reason) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.ExitReason = reason
 }
 
 func (p *Plugin) RemoveUserStalePosts(ctx context.Context, opts ArchiverOpts) (results *ArchiverResults, retErr error) {
-	results = &ArchiverResults{
-		PostsDeleted: 0,
-		ExitReason:   ReasonDone,
-		start:        time.Now(),
+	if opts.DryRun {
+		opts.Resume = nil
+	}
+	results = newArchiverResults(opts.Resume)
+	if opts.Resume == nil && opts.RunID != "" {
+		results.RunID = opts.RunID
 	}
 
 	defer func() {
@@ -48,9 +201,17 @@ func (p *Plugin) RemoveUserStalePosts(ctx context.Context, opts ArchiverOpts) (r
 		results.Duration = time.Since(results.start)
 	}()
 
-	maxWarns := opts.MaxWarnings
-	if maxWarns <= 0 {
-		maxWarns = 100
+	if opts.MaxWarnings <= 0 {
+		opts.MaxWarnings = 100
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.Throttle == nil {
+		opts.Throttle = throttle.New(opts.BatchSize)
+	}
+	if opts.DryRun {
+		results.DryRunReport = kvstore.NewDryRunReport()
 	}
 
 	userIds, err := p.kvStore.GetActiveUsers()
@@ -59,65 +220,355 @@ func (p *Plugin) RemoveUserStalePosts(ctx context.Context, opts ArchiverOpts) (r
 		p.API.LogError("Cannot fetch active users", "error", err)
 		return results, fmt.Errorf("cannot fetch active users: %w", err)
 	}
-	p.API.LogDebug("Removing stale posts.", "usersCount", len(userIds))
+	userIds = rotateFrom(userIds, results.usersFrom)
+	p.API.LogDebug("Removing stale posts.", "usersCount", len(userIds), "workers", opts.Workers)
+
+	if cancelled, err := p.removeUsersStalePosts(ctx, userIds, opts, results); err != nil || cancelled {
+		return results, err
+	}
+
+	channelIds, err := p.kvStore.GetActiveChannels()
+	if err != nil {
+		results.ExitReason = ReasonError
+		p.API.LogError("Cannot fetch active channels", "error", err)
+		return results, fmt.Errorf("cannot fetch active channels: %w", err)
+	}
+	p.API.LogDebug("Removing stale channel posts.", "channelsCount", len(channelIds))
+
+	for i := results.startIndex(phaseChannels, channelIds); i < len(channelIds); i++ {
+		pos := results.enter(phaseChannels, i, channelIds[i])
+		cancelled, err := p.removeChannelStalePosts(ctx, pos, opts, results)
+		// an interrupted scope stays tracked for the checkpoint of the stopped run
+		if err != nil || cancelled {
+			return results, err
+		}
+		results.leave(pos)
+	}
+
+	teamIds, err := p.kvStore.GetActiveTeams()
+	if err != nil {
+		results.ExitReason = ReasonError
+		p.API.LogError("Cannot fetch active teams", "error", err)
+		return results, fmt.Errorf("cannot fetch active teams: %w", err)
+	}
+	p.API.LogDebug("Removing stale team posts.", "teamsCount", len(teamIds))
+
+	for i := results.startIndex(phaseTeams, teamIds); i < len(teamIds); i++ {
+		pos := results.enter(phaseTeams, i, teamIds[i])
+		cancelled, err := p.removeTeamStalePosts(ctx, pos, opts, results)
+		if err != nil || cancelled {
+			return results, err
+		}
+		results.leave(pos)
+	}
+
+	return results, nil
+}
+
+// removeUsersStalePosts applies the user policies with opts.Workers users processed concurrently. The first
+// error stops all workers.
+func (p *Plugin) removeUsersStalePosts(ctx context.Context, userIds []string, opts ArchiverOpts, results *ArchiverResults) (bool, error) {
+	workerCtx, cancelWorkers := context.WithCancel(ctx)
+	defer cancelWorkers()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() { firstErr = err })
+		cancelWorkers()
+	}
+
+	positions := make(chan *runPosition)
+	for w := 0; w < opts.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					fail(fmt.Errorf("panic recovered: %v", r))
+				}
+			}()
+
+			for pos := range positions {
+				// a scope dequeued once the run is cancelled stays tracked but untouched, for the checkpoint to resume from
+				if workerCtx.Err() != nil {
+					return
+				}
+				cancelled, err := p.removeUserStalePosts(workerCtx, pos, opts, results)
+				if err != nil {
+					fail(err)
+					continue
+				}
+				// interrupted scopes stay tracked, so no later checkpoint skips them
+				if !cancelled {
+					results.leave(pos)
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for i := results.startIndex(phaseUsers, userIds); i < len(userIds); i++ {
+		// entered here rather than by the worker, so scopes are tracked in order
+		pos := results.enter(phaseUsers, i, userIds[i])
+		select {
+		case positions <- pos:
+		case <-workerCtx.Done():
+			results.leave(pos)
+			break dispatch
+		}
+	}
+	close(positions)
+	wg.Wait()
+
+	if firstErr != nil {
+		return false, firstErr
+	}
+	if ctx.Err() != nil {
+		results.setExitReason(ReasonCancelled)
+		return true, nil
+	}
+	return false, nil
+}
+
+// removeUserStalePosts applies the policy of the user at pos.
+func (p *Plugin) removeUserStalePosts(ctx context.Context, pos *runPosition, opts ArchiverOpts, results *ArchiverResults) (bool, error) {
+	userId := pos.scopeID
+	userPrefs, err := p.kvStore.GetUserSettings(userId)
+	if err != nil {
+		p.API.LogError("Cannot fetch user settings for user", "userId", userId, "error", err)
+		return false, nil
+	} else if !userPrefs.Enabled {
+		p.API.LogDebug("Skipping user with post deletion disabled", "userId", userId)
+		return false, nil
+	}
+
+	postOpts := store.StalePostOpts{
+		AgeInDays:          p.clampPostAge(opts.PostAgeBounds, userPrefs.RetentionInDays(), "userId", userId),
+		UserId:             userId,
+		ExcludePinned:      !userPrefs.DeletePinned,
+		ExcludeFlagged:     !userPrefs.DeleteSaved,
+		ThreadMode:         store.ThreadModeFromString(userPrefs.ThreadMode),
+		AgeBasis:           store.AgeBasisFromString(userPrefs.AgeBasis),
+		ChannelTypes:       userPrefs.ChannelTypes,
+		ExcludedChannelIds: userPrefs.ExcludedChannelIDs,
+		KeepReactionEmoji:  opts.KeepReactionEmoji,
+	}
+	return p.removeStalePosts(ctx, pos, postOpts, opts, results)
+}
+
+// removeChannelStalePosts applies the policy of the channel at pos.
+func (p *Plugin) removeChannelStalePosts(ctx context.Context, pos *runPosition, opts ArchiverOpts, results *ArchiverResults) (bool, error) {
+	channelId := pos.scopeID
+	channelPrefs, err := p.kvStore.GetChannelSettings(channelId)
+	if err != nil {
+		p.API.LogError("Cannot fetch channel settings for channel", "channelId", channelId, "error", err)
+		return false, nil
+	} else if !channelPrefs.Enabled {
+		p.API.LogDebug("Skipping channel with post deletion disabled", "channelId", channelId)
+		return false, nil
+	}
+
+	postOpts := store.StalePostOpts{
+		AgeInDays:         p.clampPostAge(opts.PostAgeBounds, channelPrefs.PostAgeInDays, "channelId", channelId),
+		ChannelId:         channelId,
+		ExcludePinned:     true,
+		ExcludeFlagged:    true,
+		ThreadMode:        store.ThreadModeFromString(channelPrefs.ThreadMode),
+		AgeBasis:          store.AgeBasisFromString(channelPrefs.AgeBasis),
+		KeepReactionEmoji: opts.KeepReactionEmoji,
+	}
+	return p.removeStalePosts(ctx, pos, postOpts, opts, results)
+}
 
-	for _, userId := range userIds {
-		userPrefs, err := p.kvStore.GetUserSettings(userId)
+// removeTeamStalePosts applies the policy of the team at pos to the posts of every team member in the
+// team's channels, resolving the effective policy against each member's own settings.
+func (p *Plugin) removeTeamStalePosts(ctx context.Context, pos *runPosition, opts ArchiverOpts, results *ArchiverResults) (bool, error) {
+	teamId := pos.scopeID
+	teamPrefs, err := p.kvStore.GetTeamSettings(teamId)
+	if err != nil {
+		p.API.LogError("Cannot fetch team settings for team", "teamId", teamId, "error", err)
+		return false, nil
+	} else if !teamPrefs.Enabled {
+		p.API.LogDebug("Skipping team with post deletion disabled", "teamId", teamId)
+		return false, nil
+	}
+	teamPrefs.PostAgeInDays = p.clampPostAge(opts.PostAgeBounds, teamPrefs.PostAgeInDays, "teamId", teamId)
+
+	firstMember := results.resumeMember(pos)
+	for page := firstMember / teamMembersPageSize; ; page++ {
+		members, err := p.client.Team.ListMembers(teamId, page, teamMembersPageSize)
 		if err != nil {
-			p.API.LogError("Cannot fetch user settings for user", "userId", userId, "error", err)
-			continue
-		} else if !userPrefs.Enabled {
-			p.API.LogDebug("Skipping user with post deletion disabled", "userId", userId)
-			continue
+			results.setExitReason(ReasonError)
+			p.API.LogError("Cannot fetch team members", "teamId", teamId, "error", err)
+			return false, fmt.Errorf("cannot fetch members of team %s: %w", teamId, err)
 		}
 
-		failsCount := 0
-		for {
-			postOpts := store.StalePostOpts{
-				AgeInDays: userPrefs.PostAgeInDays,
-				UserId:    userId,
+		for i, member := range members {
+			memberIndex := page*teamMembersPageSize + i
+			if member.DeleteAt != 0 || memberIndex < firstMember {
+				continue
 			}
-			posts, more, err := p.sqlStore.GetStalePosts(postOpts, 0, opts.BatchSize)
+			pos.memberIndex = memberIndex
 
+			userPrefs, err := p.kvStore.GetUserSettings(member.UserId)
 			if err != nil {
-				results.ExitReason = ReasonError
-				p.API.LogError("Cannot fetch stale posts", "error", err)
-				return results, fmt.Errorf("cannot fetch stale posts: %w", err)
+				p.API.LogError("Cannot fetch user settings for user", "userId", member.UserId, "error", err)
+				continue
 			}
 
-			if len(posts) > 0 {
-				cmdLine := append([]string{"post", "delete"}, posts...)
-				if err := commands.Run(append(cmdLine, "--permanent", "--confirm", "--local", "--quiet")); err != nil {
-					p.API.LogError("Cannot remove stale posts", "error", err)
+			ageInDays, apply := effectiveTeamPolicy(opts.PostAgeBounds, userPrefs, teamPrefs)
+			if !apply {
+				continue
+			}
 
-					failsCount++
+			postOpts := store.StalePostOpts{
+				AgeInDays:      ageInDays,
+				UserId:         member.UserId,
+				TeamId:         teamId,
+				ExcludePinned:  true,
+				ExcludeFlagged: true,
+				ThreadMode:     store.ThreadModeFromString(teamPrefs.ThreadMode),
+				AgeBasis:       store.AgeBasisFromString(teamPrefs.AgeBasis),
+				// the member's excluded channels are honored by the team policy too
+				ExcludedChannelIds: userPrefs.ExcludedChannelIDs,
+				KeepReactionEmoji:  opts.KeepReactionEmoji,
+			}
+			if cancelled, err := p.removeStalePosts(ctx, pos, postOpts, opts, results); err != nil || cancelled {
+				return cancelled, err
+			}
+		}
 
-					if failsCount > maxWarns {
-						results.ExitReason = ReasonError
-						p.API.LogError("Cannot remove stale posts", "error", err)
+		if len(members) < teamMembersPageSize {
+			return false, nil
+		}
+	}
+}
 
-						return results, fmt.Errorf("cannot remove stale posts: %w", err)
-					}
-				}
+// effectiveTeamPolicy resolves the retention age for a member's posts in a team. The team policy is not
+// applied when it is disabled, or when the member's own enabled policy, clamped to bounds, is stricter,
+// measures age and treats threads the same way and covers all team channels, since the user pass already
+// covers those posts. teamPrefs holds the clamped team retention period.
+func effectiveTeamPolicy(bounds config.PostAgeBounds, userPrefs kvstore.UserSettings, teamPrefs kvstore.TeamSettings) (float64, bool) {
+	if !teamPrefs.Enabled {
+		return 0, false
+	}
 
-				results.PostsDeleted += len(posts)
+	userAgeInDays := bounds.Clamp(userPrefs.RetentionInDays())
+	coversTeamChannels := userPrefs.IncludesChannelType(model.ChannelTypeOpen) && userPrefs.IncludesChannelType(model.ChannelTypePrivate)
+	sameRules := store.AgeBasisFromString(userPrefs.AgeBasis) == store.AgeBasisFromString(teamPrefs.AgeBasis) &&
+		store.ThreadModeFromString(userPrefs.ThreadMode) == store.ThreadModeFromString(teamPrefs.ThreadMode)
+	if userPrefs.Enabled && coversTeamChannels && sameRules && userAgeInDays > 0 && userAgeInDays <= teamPrefs.PostAgeInDays {
+		return userAgeInDays, false
+	}
+	return teamPrefs.PostAgeInDays, true
+}
+
+// clampPostAge limits a stored retention period to the admin bounds, logging when a policy is out of range.
+func (p *Plugin) clampPostAge(bounds config.PostAgeBounds, ageInDays float64, scope string, id string) float64 {
+	clamped := bounds.Clamp(ageInDays)
+	if clamped != ageInDays {
+		p.API.LogWarn("Retention period is out of the allowed range, clamping", scope, id, "ageInDays", ageInDays, "clamped", clamped)
+	}
+	return clamped
+}
+
+// removeStalePosts deletes, batch by batch, all posts matching postOpts. Replies are removed before root
+// posts, so a root is never deleted while its thread is still being processed. It returns true when
+// the context was cancelled before all batches were processed.
+func (p *Plugin) removeStalePosts(ctx context.Context, pos *runPosition, postOpts store.StalePostOpts, opts ArchiverOpts, results *ArchiverResults) (bool, error) {
+	resumeKind, cursor := results.takeResume(pos)
+	for _, kind := range []store.PostKind{store.PostKindReply, store.PostKindRoot} {
+		if kind == store.PostKindRoot && postOpts.ThreadMode == store.ThreadModeRepliesOnly {
+			continue
+		}
+		if kind == store.PostKindReply && resumeKind == store.PostKindRoot {
+			continue
+		}
+
+		postOpts.Kind = kind
+		if cancelled, err := p.removeStalePostsOfKind(ctx, pos, postOpts, opts, results, cursor); err != nil || cancelled {
+			return cancelled, err
+		}
+		cursor = store.PostCursor{}
+	}
+	return false, nil
+}
+
+// removeStalePostsOfKind deletes the posts of one kind, starting after cursor.
+func (p *Plugin) removeStalePostsOfKind(ctx context.Context, pos *runPosition, postOpts store.StalePostOpts, opts ArchiverOpts, results *ArchiverResults, cursor store.PostCursor) (bool, error) {
+	failsCount := 0
+	// the cursor moves past every fetched post, so posts that failed to delete are retried on the next run
+	// instead of being fetched again and again
+	for {
+		fetchStart := time.Now()
+		posts, nextCursor, more, err := p.sqlStore.GetStalePosts(postOpts, cursor, opts.Throttle.BatchSize())
+		opts.Throttle.ObserveFetch(time.Since(fetchStart), err)
+
+		if err != nil {
+			results.setExitReason(ReasonError)
+			p.API.LogError("Cannot fetch stale posts", "error", err)
+			return false, fmt.Errorf("cannot fetch stale posts: %w", err)
+		}
+
+		cursor = nextCursor
+
+		if len(posts) > 0 && opts.DryRun {
+			results.mux.Lock()
+			results.DryRunReport.Add(posts)
+			total := results.DryRunReport.Total
+			results.mux.Unlock()
+
+			p.API.LogDebug("Found stale posts (dry run)", "posts", total)
+		} else if len(posts) > 0 {
+			postIds := make([]string, 0, len(posts))
+			for _, post := range posts {
+				postIds = append(postIds, post.Id)
 			}
 
-			p.API.LogInfo("Removed stale posts", "posts", results.PostsDeleted)
+			deleteResults := opts.Deleter.DeletePosts(ctx, postIds)
+
+			results.mux.Lock()
+			deleteErr := results.record(posts, deleteResults)
+			deleted, failed := results.PostsDeleted, results.PostsFailed
+			results.mux.Unlock()
+
+			if deleteErr != nil {
+				p.API.LogError("Cannot remove some stale posts", "failed", failed, "error", deleteErr)
+
+				failsCount++
+
+				if failsCount > opts.MaxWarnings {
+					results.setExitReason(ReasonError)
+					p.API.LogError("Cannot remove stale posts", "error", deleteErr)
 
-			if !more {
-				break
+					return false, fmt.Errorf("cannot remove stale posts: %w", deleteErr)
+				}
 			}
 
-			// sleep so we don't peg the cpu; longer here to allow websocket events to flush
-			select {
-			case <-time.After(time.Second * 5):
-			case <-ctx.Done():
-				results.ExitReason = ReasonCancelled
-				return results, nil
+			p.API.LogInfo("Removed stale posts", "posts", deleted)
+
+			// a batch interrupted by cancellation is not checkpointed, so its skipped posts are retried on resume
+			if ctx.Err() != nil {
+				results.setExitReason(ReasonCancelled)
+				return true, nil
 			}
+			p.saveCheckpoint(results, pos, postOpts.Kind, cursor)
 		}
-	}
 
-	return results, nil
+		if !more {
+			return false, nil
+		}
+
+		// pause between batches, as long as the throttle needs to keep the job invisible to users
+		select {
+		case <-time.After(opts.Throttle.Adjust()):
+		case <-ctx.Done():
+			results.setExitReason(ReasonCancelled)
+			return true, nil
+		}
+	}
 }
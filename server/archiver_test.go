@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/config"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/deleter"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store/kvstore"
+)
+
+func TestArchiverResultsRecord(t *testing.T) {
+	assert := assert.New(t)
+	results := &ArchiverResults{PerUser: map[string]*PostCounts{}}
+
+	posts := []*model.Post{
+		{Id: "post1", UserId: "user1"},
+		{Id: "post2", UserId: "user1"},
+		{Id: "post3", UserId: "user2"},
+		{Id: "post4", UserId: "user2"},
+	}
+	failure := errors.New("boom")
+
+	err := results.record(posts, []deleter.Result{
+		{PostID: "post1"},
+		{PostID: "post2", Err: failure},
+		{PostID: "post3"},
+		{PostID: "post4", Err: context.Canceled},
+	})
+
+	assert.ErrorIs(err, failure)
+	assert.Equal(2, results.PostsDeleted)
+	assert.Equal(1, results.PostsFailed)
+	assert.Equal(1, results.PostsSkipped)
+	assert.Equal([]FailedPost{{PostID: "post2", UserID: "user1", Error: "boom"}}, results.FailedPosts)
+	assert.Equal(&PostCounts{Deleted: 1, Failed: 1}, results.PerUser["user1"])
+	assert.Equal(&PostCounts{Deleted: 1, Skipped: 1}, results.PerUser["user2"])
+}
+
+func TestEffectiveTeamPolicy(t *testing.T) {
+	team := kvstore.TeamSettings{TeamID: "team1", Enabled: true, PostAgeInDays: 30}
+	user := kvstore.UserSettings{UserID: "user1", Enabled: true, PostAgeInDays: 10}
+
+	for _, tc := range []struct {
+		name      string
+		bounds    config.PostAgeBounds
+		user      func(*kvstore.UserSettings)
+		team      func(*kvstore.TeamSettings)
+		ageInDays float64
+		apply     bool
+	}{
+		{
+			name:  "user stricter",
+			apply: false, ageInDays: 10,
+		},
+		{
+			name:  "user as strict as the team",
+			user:  func(u *kvstore.UserSettings) { u.PostAgeInDays = 30 },
+			apply: false, ageInDays: 30,
+		},
+		{
+			name:  "user looser",
+			user:  func(u *kvstore.UserSettings) { u.PostAgeInDays = 60 },
+			apply: true, ageInDays: 30,
+		},
+		{
+			name:  "user stricter in hours",
+			user:  func(u *kvstore.UserSettings) { u.PostAgeInDays, u.PostAgeInHours = 60, 12 },
+			apply: false, ageInDays: 0.5,
+		},
+		{
+			name:  "user disabled",
+			user:  func(u *kvstore.UserSettings) { u.Enabled = false },
+			apply: true, ageInDays: 30,
+		},
+		{
+			name:  "user without a retention period",
+			user:  func(u *kvstore.UserSettings) { u.PostAgeInDays = 0 },
+			apply: true, ageInDays: 30,
+		},
+		{
+			name:  "user limited to public channels",
+			user:  func(u *kvstore.UserSettings) { u.ChannelTypes = []model.ChannelType{model.ChannelTypeOpen} },
+			apply: true, ageInDays: 30,
+		},
+		{
+			name:  "user with another thread mode",
+			user:  func(u *kvstore.UserSettings) { u.ThreadMode = string(store.ThreadModeRepliesOnly) },
+			apply: true, ageInDays: 30,
+		},
+		{
+			name:  "user with another age basis",
+			user:  func(u *kvstore.UserSettings) { u.AgeBasis = string(store.AgeBasisCreateAt) },
+			apply: true, ageInDays: 30,
+		},
+		{
+			name:  "default and explicit rules are the same",
+			user:  func(u *kvstore.UserSettings) { u.ThreadMode = string(store.DefaultThreadMode) },
+			team:  func(t *kvstore.TeamSettings) { t.AgeBasis = string(store.DefaultAgeBasis) },
+			apply: false, ageInDays: 10,
+		},
+		{
+			name:  "team disabled",
+			team:  func(t *kvstore.TeamSettings) { t.Enabled = false },
+			apply: false, ageInDays: 0,
+		},
+		{
+			name:  "team disabled and user looser",
+			user:  func(u *kvstore.UserSettings) { u.PostAgeInDays = 60 },
+			team:  func(t *kvstore.TeamSettings) { t.Enabled = false },
+			apply: false, ageInDays: 0,
+		},
+		{
+			name:   "user raised to the minimum stays stricter",
+			bounds: config.PostAgeBounds{Min: 7},
+			user:   func(u *kvstore.UserSettings) { u.PostAgeInDays = 1 },
+			apply:  false, ageInDays: 7,
+		},
+		{
+			name:   "user and team raised to the minimum",
+			bounds: config.PostAgeBounds{Min: 7},
+			user:   func(u *kvstore.UserSettings) { u.PostAgeInDays = 1 },
+			team:   func(t *kvstore.TeamSettings) { t.PostAgeInDays = 7 },
+			apply:  false, ageInDays: 7,
+		},
+		{
+			name:   "user lowered to the maximum becomes stricter",
+			bounds: config.PostAgeBounds{Max: 30},
+			user:   func(u *kvstore.UserSettings) { u.PostAgeInDays = 90 },
+			apply:  false, ageInDays: 30,
+		},
+		{
+			name:   "user lowered to the maximum stays looser",
+			bounds: config.PostAgeBounds{Max: 30},
+			user:   func(u *kvstore.UserSettings) { u.PostAgeInDays = 90 },
+			team:   func(t *kvstore.TeamSettings) { t.PostAgeInDays = 20 },
+			apply:  true, ageInDays: 20,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			userPrefs, teamPrefs := user, team
+			if tc.user != nil {
+				tc.user(&userPrefs)
+			}
+			if tc.team != nil {
+				tc.team(&teamPrefs)
+			}
+
+			ageInDays, apply := effectiveTeamPolicy(tc.bounds, userPrefs, teamPrefs)
+			assert.Equal(t, tc.apply, apply)
+			assert.Equal(t, tc.ageInDays, ageInDays)
+		})
+	}
+}
+
+func TestClampPostAge(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		bounds    config.PostAgeBounds
+		ageInDays float64
+		clamped   float64
+	}{
+		{name: "unbounded", ageInDays: 0.1, clamped: 0.1},
+		{name: "within the bounds", bounds: config.PostAgeBounds{Min: 0.25, Max: 30}, ageInDays: 7, clamped: 7},
+		{name: "at the minimum", bounds: config.PostAgeBounds{Min: 0.25, Max: 30}, ageInDays: 0.25, clamped: 0.25},
+		{name: "raised to a minimum in hours", bounds: config.PostAgeBounds{Min: 0.25, Max: 30}, ageInDays: 0.125, clamped: 0.25},
+		{name: "raised to a minimum in days", bounds: config.PostAgeBounds{Min: 1}, ageInDays: 0.5, clamped: 1},
+		{name: "lowered to the maximum", bounds: config.PostAgeBounds{Min: 0.25, Max: 30}, ageInDays: 90, clamped: 30},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			api := &plugintest.API{}
+			if tc.clamped != tc.ageInDays {
+				api.On("LogWarn", "Retention period is out of the allowed range, clamping",
+					"channelId", "channel1", "ageInDays", tc.ageInDays, "clamped", tc.clamped).Return()
+			}
+			defer api.AssertExpectations(t)
+
+			p := &Plugin{}
+			p.API = api
+			assert.Equal(t, tc.clamped, p.clampPostAge(tc.bounds, tc.ageInDays, "channelId", "channel1"))
+		})
+	}
+}
+
+// cancellingKVStore serves disabled user settings, cancelling the run when the settings of cancelAt are fetched.
+type cancellingKVStore struct {
+	kvstore.KVStore
+
+	mux      sync.Mutex
+	fetched  []string
+	cancelAt string
+	cancel   context.CancelFunc
+}
+
+func (s *cancellingKVStore) GetUserSettings(userID string) (kvstore.UserSettings, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.fetched = append(s.fetched, userID)
+	if userID == s.cancelAt {
+		s.cancel()
+	}
+	return kvstore.UserSettings{UserID: userID}, nil
+}
+
+func TestRemoveUsersStalePostsCancelled(t *testing.T) {
+	users := []string{"user1", "user2", "user3", "user4", "user5"}
+
+	for _, tc := range []struct {
+		name      string
+		workers   int
+		cancelAt  string
+		cancelled bool
+		fetched   []string
+	}{
+		{name: "cancelled mid-list", workers: 1, cancelAt: "user3", fetched: []string{"user1", "user2", "user3"}},
+		{name: "cancelled before the start", workers: 3, cancelled: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			api := &plugintest.API{}
+			api.On("LogDebug", "Skipping user with post deletion disabled", "userId", mock.Anything).Maybe()
+			defer api.AssertExpectations(t)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancelled {
+				cancel()
+			}
+
+			store := &cancellingKVStore{cancelAt: tc.cancelAt, cancel: cancel}
+			p := &Plugin{kvStore: store}
+			p.API = api
+
+			results := newArchiverResults(nil)
+			cancelled, err := p.removeUsersStalePosts(ctx, users, ArchiverOpts{Workers: tc.workers}, results)
+			assert.NoError(t, err)
+			assert.True(t, cancelled)
+			assert.Equal(t, ReasonCancelled, results.ExitReason)
+
+			// the users after the cancellation are neither processed nor counted, and stay for the checkpoint
+			assert.Equal(t, tc.fetched, store.fetched)
+			assert.Zero(t, results.PostsSkipped)
+			for pos := range results.positions {
+				assert.GreaterOrEqual(t, pos.index, len(tc.fetched))
+			}
+		})
+	}
+}
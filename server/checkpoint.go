@@ -0,0 +1,216 @@
+package main
+
+import (
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store/kvstore"
+)
+
+// Run phases, in the order a run applies the policies.
+const (
+	phaseUsers    = "users"
+	phaseChannels = "channels"
+	phaseTeams    = "teams"
+)
+
+var runPhases = []string{phaseUsers, phaseChannels, phaseTeams}
+
+func phaseOrder(phase string) int {
+	for i, p := range runPhases {
+		if p == phase {
+			return i
+		}
+	}
+	return -1
+}
+
+// runPosition is the scope a run, or one of its workers, is processing and the cursor in its posts.
+type runPosition struct {
+	phase       string
+	index       int
+	scopeID     string
+	memberIndex int
+	kind        store.PostKind
+	cursor      store.PostCursor
+}
+
+func (pos *runPosition) before(other *runPosition) bool {
+	if pos.phase != other.phase {
+		return phaseOrder(pos.phase) < phaseOrder(other.phase)
+	}
+	if pos.index != other.index {
+		return pos.index < other.index
+	}
+	return pos.memberIndex < other.memberIndex
+}
+
+// startIndex returns the position a phase starts at. Phases completed before the resumed checkpoint are
+// skipped entirely; the resumed phase starts at the checkpointed scope, realigned on its ID in case the
+// list changed meanwhile.
+func (r *ArchiverResults) startIndex(phase string, ids []string) int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.resume == nil || phaseOrder(r.resume.Phase) < phaseOrder(phase) {
+		return 0
+	}
+	if phaseOrder(r.resume.Phase) > phaseOrder(phase) {
+		return len(ids)
+	}
+
+	for i, id := range ids {
+		if id == r.resume.ScopeID {
+			return i
+		}
+	}
+
+	// the checkpointed scope is gone, so its cursor is meaningless
+	index := r.resume.Index
+	r.resume = nil
+	if index > len(ids) {
+		return len(ids)
+	}
+	return index
+}
+
+// enter starts tracking the scope at index in the phase. Scopes must be entered in order, so every scope
+// before the earliest tracked one is known to be done.
+func (r *ArchiverResults) enter(phase string, index int, scopeID string) *runPosition {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	pos := &runPosition{phase: phase, index: index, scopeID: scopeID}
+	r.positions[pos] = struct{}{}
+	return pos
+}
+
+// leave stops tracking a scope once it is done.
+func (r *ArchiverResults) leave(pos *runPosition) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	delete(r.positions, pos)
+}
+
+// resumeMember returns the team member index a team scope continues from.
+func (r *ArchiverResults) resumeMember(pos *runPosition) int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.resume == nil || r.resume.Phase != pos.phase || r.resume.ScopeID != pos.scopeID {
+		return 0
+	}
+	return r.resume.MemberIndex
+}
+
+// takeResume returns the post kind and cursor a scope continues from when it is the resumed one,
+// consuming the resume checkpoint.
+func (r *ArchiverResults) takeResume(pos *runPosition) (store.PostKind, store.PostCursor) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	resume := r.resume
+	if resume == nil || resume.Phase != pos.phase || resume.ScopeID != pos.scopeID || resume.MemberIndex != pos.memberIndex {
+		return store.PostKindAny, store.PostCursor{}
+	}
+
+	r.resume = nil
+	return store.PostKind(resume.Kind), store.PostCursor{CreateAt: resume.CursorCreateAt, PostId: resume.CursorPostID}
+}
+
+// checkpoint moves pos past a processed batch and returns the checkpoint of the whole run: the earliest
+// scope still being processed, since all scopes before it are done. Later scopes finished by other
+// workers are processed again on resume, which finds no stale posts left in them.
+func (r *ArchiverResults) checkpoint(pos *runPosition, kind store.PostKind, cursor store.PostCursor) *kvstore.RunCheckpoint {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	pos.kind = kind
+	pos.cursor = cursor
+
+	earliest := pos
+	for other := range r.positions {
+		if other.before(earliest) {
+			earliest = other
+		}
+	}
+	return r.checkpointAt(earliest)
+}
+
+// stoppedCheckpoint returns the checkpoint of a stopped run: the earliest scope it was processing, or the
+// first one left unprocessed, with the cursor of its last processed batch. It is nil when no scope is tracked.
+func (r *ArchiverResults) stoppedCheckpoint() *kvstore.RunCheckpoint {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	var earliest *runPosition
+	for pos := range r.positions {
+		if earliest == nil || pos.before(earliest) {
+			earliest = pos
+		}
+	}
+	if earliest == nil {
+		return nil
+	}
+	return r.checkpointAt(earliest)
+}
+
+// checkpointAt returns the checkpoint of the run at pos. The caller must hold the results mutex.
+func (r *ArchiverResults) checkpointAt(pos *runPosition) *kvstore.RunCheckpoint {
+	return &kvstore.RunCheckpoint{
+		RunID:          r.RunID,
+		StartedAt:      r.startedAt,
+		UpdatedAt:      model.GetMillis(),
+		Phase:          pos.phase,
+		Index:          pos.index,
+		ScopeID:        pos.scopeID,
+		MemberIndex:    pos.memberIndex,
+		Kind:           string(pos.kind),
+		CursorCreateAt: pos.cursor.CreateAt,
+		CursorPostID:   pos.cursor.PostId,
+		PostsDeleted:   r.PostsDeleted,
+		PostsFailed:    r.PostsFailed,
+		PostsSkipped:   r.PostsSkipped,
+		UsersFrom:      r.usersFrom,
+	}
+}
+
+// continueDeferred starts a new run from the checkpoint of a deferred run. When users
+// were left over, the new run starts with them, continuing the interrupted user where it stopped, and
+// processes the users done by the previous run after them.
+func continueDeferred(deferred *kvstore.RunCheckpoint) *kvstore.RunCheckpoint {
+	next := &kvstore.RunCheckpoint{
+		RunID:     model.NewId(),
+		StartedAt: model.GetMillis(),
+		UsersFrom: deferred.UsersFrom,
+	}
+	if deferred.Phase != phaseUsers {
+		return next
+	}
+
+	next.Phase = phaseUsers
+	next.ScopeID = deferred.ScopeID
+	next.Kind = deferred.Kind
+	next.CursorCreateAt = deferred.CursorCreateAt
+	next.CursorPostID = deferred.CursorPostID
+	next.UsersFrom = deferred.ScopeID
+	return next
+}
+
+// rotateFrom returns ids starting at the first ID, followed by those before it. ids is returned as is
+// when first is empty or missing.
+func rotateFrom(ids []string, first string) []string {
+	for i, id := range ids {
+		if id == first {
+			return append(append(make([]string, 0, len(ids)), ids[i:]...), ids[:i]...)
+		}
+	}
+	return ids
+}
+
+// saveCheckpoint persists the position and totals of the run after a batch, so an interrupted run can be resumed.
+func (p *Plugin) saveCheckpoint(results *ArchiverResults, pos *runPosition, kind store.PostKind, cursor store.PostCursor) {
+	if err := p.kvStore.SetRunCheckpoint(results.checkpoint(pos, kind, cursor)); err != nil {
+		p.API.LogWarn("Cannot save Posts Retention checkpoint", "error", err)
+	}
+}
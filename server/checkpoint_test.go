@@ -0,0 +1,365 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store/kvstore"
+)
+
+func TestStartIndex(t *testing.T) {
+	users := []string{"user1", "user2", "user3", "user4"}
+
+	for _, tc := range []struct {
+		name     string
+		resume   *kvstore.RunCheckpoint
+		phase    string
+		ids      []string
+		expected int
+		// resumed reports whether the checkpoint cursor is still used by the scope at the start index
+		resumed bool
+	}{
+		{
+			name:  "new run",
+			phase: phaseUsers, ids: users,
+			expected: 0,
+		},
+		{
+			name:   "mid-list user",
+			resume: &kvstore.RunCheckpoint{Phase: phaseUsers, Index: 2, ScopeID: "user3"},
+			phase:  phaseUsers, ids: users,
+			expected: 2, resumed: true,
+		},
+		{
+			name:   "user added before the resumed one",
+			resume: &kvstore.RunCheckpoint{Phase: phaseUsers, Index: 2, ScopeID: "user3"},
+			phase:  phaseUsers, ids: []string{"user0", "user1", "user2", "user3", "user4"},
+			expected: 3, resumed: true,
+		},
+		{
+			name:   "user removed before the resumed one",
+			resume: &kvstore.RunCheckpoint{Phase: phaseUsers, Index: 2, ScopeID: "user3"},
+			phase:  phaseUsers, ids: []string{"user1", "user3", "user4"},
+			expected: 1, resumed: true,
+		},
+		{
+			name:   "resumed user removed",
+			resume: &kvstore.RunCheckpoint{Phase: phaseUsers, Index: 2, ScopeID: "user3"},
+			phase:  phaseUsers, ids: []string{"user1", "user2", "user4"},
+			expected: 2,
+		},
+		{
+			name:   "resumed user removed from the end of the list",
+			resume: &kvstore.RunCheckpoint{Phase: phaseUsers, Index: 3, ScopeID: "user4"},
+			phase:  phaseUsers, ids: []string{"user1", "user2"},
+			expected: 2,
+		},
+		{
+			name:   "phase after the resumed one",
+			resume: &kvstore.RunCheckpoint{Phase: phaseUsers, Index: 2, ScopeID: "user3"},
+			phase:  phaseChannels, ids: []string{"channel1", "channel2"},
+			expected: 0, resumed: true,
+		},
+		{
+			name:   "phase before the resumed one",
+			resume: &kvstore.RunCheckpoint{Phase: phaseTeams, Index: 1, ScopeID: "team2"},
+			phase:  phaseUsers, ids: users,
+			expected: len(users), resumed: true,
+		},
+		{
+			name:   "resumed team",
+			resume: &kvstore.RunCheckpoint{Phase: phaseTeams, Index: 1, ScopeID: "team2", MemberIndex: 250},
+			phase:  phaseTeams, ids: []string{"team1", "team2", "team3"},
+			expected: 1, resumed: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			results := newArchiverResults(tc.resume)
+
+			assert.Equal(t, tc.expected, results.startIndex(tc.phase, tc.ids))
+			assert.Equal(t, tc.resumed, results.resume != nil)
+		})
+	}
+}
+
+func TestResumeScope(t *testing.T) {
+	resume := &kvstore.RunCheckpoint{
+		RunID:          "run1",
+		Phase:          phaseTeams,
+		Index:          1,
+		ScopeID:        "team2",
+		MemberIndex:    250,
+		Kind:           string(store.PostKindRoot),
+		CursorCreateAt: 1000,
+		CursorPostID:   "post1",
+		PostsDeleted:   10,
+	}
+	results := newArchiverResults(resume)
+	assert.Equal(t, "run1", results.RunID)
+	assert.Equal(t, 10, results.PostsDeleted)
+
+	other := results.enter(phaseTeams, 0, "team1")
+	assert.Equal(t, 0, results.resumeMember(other))
+	kind, cursor := results.takeResume(other)
+	assert.Equal(t, store.PostKindAny, kind)
+	assert.True(t, cursor.IsZero())
+
+	pos := results.enter(phaseTeams, 1, "team2")
+	assert.Equal(t, 250, results.resumeMember(pos))
+
+	// only the member the run stopped at continues from the cursor
+	pos.memberIndex = 249
+	kind, cursor = results.takeResume(pos)
+	assert.Equal(t, store.PostKindAny, kind)
+	assert.True(t, cursor.IsZero())
+
+	pos.memberIndex = 250
+	kind, cursor = results.takeResume(pos)
+	assert.Equal(t, store.PostKindRoot, kind)
+	assert.Equal(t, store.PostCursor{CreateAt: 1000, PostId: "post1"}, cursor)
+
+	// the cursor is used once
+	kind, cursor = results.takeResume(pos)
+	assert.Equal(t, store.PostKindAny, kind)
+	assert.True(t, cursor.IsZero())
+}
+
+func TestCheckpoint(t *testing.T) {
+	t.Run("single worker", func(t *testing.T) {
+		results := newArchiverResults(&kvstore.RunCheckpoint{RunID: "run1", StartedAt: 500, UsersFrom: "user3", PostsDeleted: 10})
+		results.PostsDeleted += 5
+		results.PostsFailed = 1
+
+		pos := results.enter(phaseUsers, 2, "user3")
+		checkpoint := results.checkpoint(pos, store.PostKindReply, store.PostCursor{CreateAt: 1000, PostId: "post1"})
+
+		assert.Equal(t, "run1", checkpoint.RunID)
+		assert.Equal(t, int64(500), checkpoint.StartedAt)
+		assert.Equal(t, phaseUsers, checkpoint.Phase)
+		assert.Equal(t, 2, checkpoint.Index)
+		assert.Equal(t, "user3", checkpoint.ScopeID)
+		assert.Equal(t, string(store.PostKindReply), checkpoint.Kind)
+		assert.Equal(t, int64(1000), checkpoint.CursorCreateAt)
+		assert.Equal(t, "post1", checkpoint.CursorPostID)
+		assert.Equal(t, 15, checkpoint.PostsDeleted)
+		assert.Equal(t, 1, checkpoint.PostsFailed)
+		assert.Equal(t, "user3", checkpoint.UsersFrom)
+		assert.False(t, checkpoint.Deferred)
+	})
+
+	for _, tc := range []struct {
+		name string
+		// positions are entered in order; the checkpoint is taken after a batch of the last one
+		positions []runPosition
+		earliest  int
+	}{
+		{
+			name: "earliest user in flight",
+			positions: []runPosition{
+				{phase: phaseUsers, index: 3, scopeID: "user4"},
+				{phase: phaseUsers, index: 5, scopeID: "user6"},
+				{phase: phaseUsers, index: 4, scopeID: "user5"},
+			},
+			earliest: 0,
+		},
+		{
+			name: "checkpointing worker is the earliest",
+			positions: []runPosition{
+				{phase: phaseUsers, index: 5, scopeID: "user6"},
+				{phase: phaseUsers, index: 2, scopeID: "user3"},
+			},
+			earliest: 1,
+		},
+		{
+			name: "earlier phase",
+			positions: []runPosition{
+				{phase: phaseChannels, index: 0, scopeID: "channel1"},
+				{phase: phaseUsers, index: 7, scopeID: "user8"},
+			},
+			earliest: 1,
+		},
+		{
+			name: "earlier team member",
+			positions: []runPosition{
+				{phase: phaseTeams, index: 1, scopeID: "team2", memberIndex: 400},
+				{phase: phaseTeams, index: 1, scopeID: "team2", memberIndex: 12},
+			},
+			earliest: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			results := newArchiverResults(nil)
+
+			var entered []*runPosition
+			for i, position := range tc.positions {
+				pos := results.enter(position.phase, position.index, position.scopeID)
+				pos.memberIndex = position.memberIndex
+				// every worker has processed a batch of its scope
+				results.checkpoint(pos, store.PostKindReply, store.PostCursor{CreateAt: int64(i + 1), PostId: position.scopeID})
+				entered = append(entered, pos)
+			}
+
+			last := entered[len(entered)-1]
+			checkpoint := results.checkpoint(last, store.PostKindRoot, store.PostCursor{CreateAt: 100, PostId: "last"})
+
+			earliest := entered[tc.earliest]
+			assert.Equal(t, earliest.phase, checkpoint.Phase)
+			assert.Equal(t, earliest.index, checkpoint.Index)
+			assert.Equal(t, earliest.scopeID, checkpoint.ScopeID)
+			assert.Equal(t, earliest.memberIndex, checkpoint.MemberIndex)
+			// the cursor is the one of the earliest scope, not of the worker saving the checkpoint
+			assert.Equal(t, string(earliest.kind), checkpoint.Kind)
+			assert.Equal(t, earliest.cursor.CreateAt, checkpoint.CursorCreateAt)
+			assert.Equal(t, earliest.cursor.PostId, checkpoint.CursorPostID)
+		})
+	}
+
+	t.Run("earliest scope done", func(t *testing.T) {
+		results := newArchiverResults(nil)
+		first := results.enter(phaseUsers, 0, "user1")
+		second := results.enter(phaseUsers, 1, "user2")
+		third := results.enter(phaseUsers, 2, "user3")
+
+		results.checkpoint(second, store.PostKindReply, store.PostCursor{CreateAt: 20, PostId: "post2"})
+		results.leave(first)
+		checkpoint := results.checkpoint(third, store.PostKindReply, store.PostCursor{CreateAt: 30, PostId: "post3"})
+		assert.Equal(t, "user2", checkpoint.ScopeID)
+		assert.Equal(t, int64(20), checkpoint.CursorCreateAt)
+
+		results.leave(second)
+		checkpoint = results.checkpoint(third, store.PostKindReply, store.PostCursor{CreateAt: 31, PostId: "post4"})
+		assert.Equal(t, "user3", checkpoint.ScopeID)
+		assert.Equal(t, int64(31), checkpoint.CursorCreateAt)
+	})
+}
+
+func TestContinueDeferred(t *testing.T) {
+	t.Run("users left over", func(t *testing.T) {
+		deferred := &kvstore.RunCheckpoint{
+			RunID:          "run1",
+			StartedAt:      500,
+			Phase:          phaseUsers,
+			Index:          2,
+			ScopeID:        "user3",
+			Kind:           string(store.PostKindRoot),
+			CursorCreateAt: 1000,
+			CursorPostID:   "post1",
+			PostsDeleted:   10,
+			UsersFrom:      "user2",
+			Deferred:       true,
+		}
+
+		next := continueDeferred(deferred)
+		assert.NotEqual(t, "run1", next.RunID)
+		assert.NotEqual(t, int64(500), next.StartedAt)
+		assert.Equal(t, phaseUsers, next.Phase)
+		assert.Equal(t, "user3", next.ScopeID)
+		assert.Equal(t, "user3", next.UsersFrom)
+		assert.Equal(t, string(store.PostKindRoot), next.Kind)
+		assert.Equal(t, int64(1000), next.CursorCreateAt)
+		assert.Equal(t, "post1", next.CursorPostID)
+		// the new run counts its own deletions
+		assert.Zero(t, next.PostsDeleted)
+		assert.False(t, next.Deferred)
+	})
+
+	for _, phase := range []string{phaseChannels, phaseTeams} {
+		t.Run("stopped in the "+phase+" phase", func(t *testing.T) {
+			deferred := &kvstore.RunCheckpoint{RunID: "run1", Phase: phase, Index: 1, ScopeID: "scope2", UsersFrom: "user2", Deferred: true}
+
+			next := continueDeferred(deferred)
+			assert.NotEqual(t, "run1", next.RunID)
+			assert.Empty(t, next.Phase)
+			assert.Empty(t, next.ScopeID)
+			assert.Empty(t, next.CursorPostID)
+			// the users keep the order of the last run
+			assert.Equal(t, "user2", next.UsersFrom)
+		})
+	}
+}
+
+func TestRotateFrom(t *testing.T) {
+	users := []string{"user1", "user2", "user3", "user4"}
+
+	for _, tc := range []struct {
+		name     string
+		ids      []string
+		first    string
+		expected []string
+	}{
+		{"no rotation", users, "", users},
+		{"first user", users, "user1", users},
+		{"mid-list user", users, "user3", []string{"user3", "user4", "user1", "user2"}},
+		{"last user", users, "user4", []string{"user4", "user1", "user2", "user3"}},
+		{"user gone", users, "user9", users},
+		{"no users", nil, "user1", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, rotateFrom(tc.ids, tc.first))
+		})
+	}
+
+	rotateFrom(users, "user3")
+	assert.Equal(t, []string{"user1", "user2", "user3", "user4"}, users, "ids must not be modified")
+}
+
+func TestDeferredUsersGoFirst(t *testing.T) {
+	// the last run stopped at the window end while processing user3
+	deferred := &kvstore.RunCheckpoint{
+		RunID:          "run1",
+		Phase:          phaseUsers,
+		Index:          2,
+		ScopeID:        "user3",
+		Kind:           string(store.PostKindReply),
+		CursorCreateAt: 1000,
+		CursorPostID:   "post1",
+		Deferred:       true,
+	}
+	// user5 joined since, and user1 left
+	activeUsers := []string{"user2", "user3", "user4", "user5"}
+
+	results := newArchiverResults(continueDeferred(deferred))
+	userIds := rotateFrom(activeUsers, results.usersFrom)
+	assert.Equal(t, []string{"user3", "user4", "user5", "user2"}, userIds)
+
+	start := results.startIndex(phaseUsers, userIds)
+	assert.Equal(t, 0, start)
+	pos := results.enter(phaseUsers, start, userIds[start])
+	kind, cursor := results.takeResume(pos)
+	assert.Equal(t, store.PostKindReply, kind)
+	assert.Equal(t, store.PostCursor{CreateAt: 1000, PostId: "post1"}, cursor)
+
+	// the next checkpoint keeps the rotation, so a resume after an interruption sees the same order
+	checkpoint := results.checkpoint(pos, store.PostKindReply, store.PostCursor{CreateAt: 2000, PostId: "post2"})
+	assert.Equal(t, "user3", checkpoint.UsersFrom)
+	assert.Equal(t, 0, checkpoint.Index)
+}
+
+func TestStoppedCheckpoint(t *testing.T) {
+	results := newArchiverResults(&kvstore.RunCheckpoint{RunID: "run1", UsersFrom: "user3"})
+	assert.Nil(t, results.stoppedCheckpoint())
+
+	// the window closed before a batch of the users in flight was deleted
+	first := results.enter(phaseUsers, 1, "user4")
+	results.enter(phaseUsers, 2, "user5")
+
+	checkpoint := results.stoppedCheckpoint()
+	if assert.NotNil(t, checkpoint) {
+		assert.Equal(t, "run1", checkpoint.RunID)
+		assert.Equal(t, phaseUsers, checkpoint.Phase)
+		assert.Equal(t, 1, checkpoint.Index)
+		assert.Equal(t, "user4", checkpoint.ScopeID)
+		assert.Empty(t, checkpoint.Kind)
+		assert.Zero(t, checkpoint.CursorCreateAt)
+		assert.Equal(t, "user3", checkpoint.UsersFrom)
+	}
+
+	results.checkpoint(first, store.PostKindReply, store.PostCursor{CreateAt: 1000, PostId: "post1"})
+	checkpoint = results.stoppedCheckpoint()
+	if assert.NotNil(t, checkpoint) {
+		assert.Equal(t, "user4", checkpoint.ScopeID)
+		assert.Equal(t, int64(1000), checkpoint.CursorCreateAt)
+	}
+}
@@ -2,17 +2,66 @@ package command
 
 import (
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store"
 	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store/kvstore"
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/pluginapi"
 )
 
+// ChannelTypeOption is a channel type a user policy can be limited to.
+type ChannelTypeOption struct {
+	Type        model.ChannelType
+	DisplayName string
+}
+
+// ChannelTypeOptions lists the channel types a user policy can be limited to, in display order.
+var ChannelTypeOptions = []ChannelTypeOption{
+	{Type: model.ChannelTypeDirect, DisplayName: "Direct messages"},
+	{Type: model.ChannelTypeGroup, DisplayName: "Group messages"},
+	{Type: model.ChannelTypePrivate, DisplayName: "Private channels"},
+	{Type: model.ChannelTypeOpen, DisplayName: "Public channels"},
+}
+
+// KeepReaction describes the reaction protecting a user's posts from deletion.
+type KeepReaction struct {
+	// EmojiName is empty when the keep reaction is disabled.
+	EmojiName string
+	// Count is the number of the user's posts protected by the reaction.
+	Count int
+}
+
+// Retention exposes the plugin state the slash commands report on and the control of the retention job.
+type Retention interface {
+	GetKeepReaction(userID string) (KeepReaction, error)
+	// RunNow starts a retention run in the background; it fails when a run is already in progress.
+	RunNow(dryRun bool) error
+	// CancelRun cancels the retention run in progress, wherever it executes. It reports whether a run was
+	// in progress.
+	CancelRun() (bool, error)
+	// GetRunStatus returns the run in progress or the last run, nil when none ran yet.
+	GetRunStatus() (*kvstore.RunStatus, error)
+	// GetNextRun returns when the scheduled job runs next, or the zero time when it is disabled.
+	GetNextRun() (time.Time, error)
+	// GetUpcomingRuns returns the next count runs of the scheduled job in the time zone of the schedule,
+	// none when it is disabled.
+	GetUpcomingRuns(count int) ([]time.Time, error)
+	// Freeze pauses the retention job until a duration from now or the end of a date, and cancels the run
+	// in progress. It returns when the freeze ends, also with the error of a failed cancellation.
+	Freeze(until string, reason string, userID string) (time.Time, error)
+	// Unfreeze lifts the freeze.
+	Unfreeze(userID string) error
+}
+
 type Handler struct {
 	client *pluginapi.Client
 	// kvStore is the client used to read/write KV records for this plugin.
 	kvStore kvstore.KVStore
+	// retention is the plugin state the commands report on.
+	retention Retention
 	// botUser used for messaging
 	//botUser *rbot.Bot
 }
@@ -22,23 +71,70 @@ type Command interface {
 	executeCommandInteractive(args *model.CommandArgs) *model.CommandResponse
 }
 
-const postRetentionCommandTrigger = "post-retention"
+const (
+	postRetentionCommandTrigger = "post-retention"
+
+	channelSubcommand = "channel"
+	teamSubcommand    = "team"
+	excludeSubcommand = "exclude"
+
+	excludeAddAction    = "add"
+	excludeRemoveAction = "remove"
+	excludeListAction   = "list"
+
+	runSubcommand      = "run"
+	cancelSubcommand   = "cancel"
+	statusSubcommand   = "status"
+	nextSubcommand     = "next"
+	historySubcommand  = "history"
+	freezeSubcommand   = "freeze"
+	unfreezeSubcommand = "unfreeze"
+	scheduleSubcommand = "schedule"
+
+	dryRunFlag = "--dry-run"
+)
 
 // NewCommandHandler Register all your slash commands.
-func NewCommandHandler(client *pluginapi.Client, kvStore kvstore.KVStore) Command {
+func NewCommandHandler(client *pluginapi.Client, kvStore kvstore.KVStore, retention Retention) Command {
+	autocomplete := model.NewAutocompleteData(postRetentionCommandTrigger, "", "Post retention management.")
+	autocomplete.AddCommand(model.NewAutocompleteData(channelSubcommand, "", "Manage the retention policy of the current channel (channel admins only)."))
+	autocomplete.AddCommand(model.NewAutocompleteData(teamSubcommand, "", "Manage the default retention policy of the current team (team admins only)."))
+	exclude := model.NewAutocompleteData(excludeSubcommand, "[add|remove|list]", "Manage the channels where your posts are never purged.")
+	exclude.AddCommand(model.NewAutocompleteData(excludeAddAction, "", "Never purge your posts in the current channel."))
+	exclude.AddCommand(model.NewAutocompleteData(excludeRemoveAction, "", "Purge your posts in the current channel again."))
+	exclude.AddCommand(model.NewAutocompleteData(excludeListAction, "", "List the channels where your posts are never purged."))
+	autocomplete.AddCommand(exclude)
+	run := model.NewAutocompleteData(runSubcommand, "[--dry-run]", "Run the retention job now (system admins only).")
+	run.AddStaticListArgument("", false, []model.AutocompleteListItem{{Item: dryRunFlag, HelpText: "Only report what would be deleted."}})
+	autocomplete.AddCommand(run)
+	autocomplete.AddCommand(model.NewAutocompleteData(cancelSubcommand, "", "Cancel the retention run in progress (system admins only)."))
+	autocomplete.AddCommand(model.NewAutocompleteData(statusSubcommand, "", "Show the retention run in progress or the last one (system admins only)."))
+	autocomplete.AddCommand(model.NewAutocompleteData(nextSubcommand, "", "Show when the retention job runs next (system admins only)."))
+	autocomplete.AddCommand(model.NewAutocompleteData(historySubcommand, "", "List the last retention runs (system admins only)."))
+	schedule := model.NewAutocompleteData(scheduleSubcommand, "[count]", "List the next scheduled retention runs (system admins only).")
+	schedule.AddTextArgument(fmt.Sprintf("Number of runs, %d by default", DefaultUpcomingRuns), "[count]", "")
+	autocomplete.AddCommand(schedule)
+	freeze := model.NewAutocompleteData(freezeSubcommand, "<12h|3d|2006-01-02> [reason]", "Pause all deletion until a duration from now or the end of a date (system admins only).")
+	freeze.AddTextArgument("Duration, such as 12h or 3d, or last frozen date", "<12h|3d|2006-01-02>", "")
+	freeze.AddTextArgument("Reason of the freeze, shown in the status", "[reason]", "")
+	autocomplete.AddCommand(freeze)
+	autocomplete.AddCommand(model.NewAutocompleteData(unfreezeSubcommand, "", "Resume the retention job before the freeze ends (system admins only)."))
+
 	err := client.SlashCommand.Register(&model.Command{
 		Trigger:          postRetentionCommandTrigger,
 		AutoComplete:     true,
 		AutoCompleteHint: "",
 		AutoCompleteDesc: "Post retention management.",
+		AutocompleteData: autocomplete,
 	})
 	if err != nil {
 		client.Log.Error("Failed to register command", "error", err)
 	}
 
 	return &Handler{
-		client:  client,
-		kvStore: kvStore,
+		client:    client,
+		kvStore:   kvStore,
+		retention: retention,
 	}
 }
 
@@ -54,6 +150,9 @@ func (c *Handler) Handle(args *model.CommandArgs) (*model.CommandResponse, error
 	trigger := strings.TrimPrefix(fields[0], "/")
 	switch trigger {
 	case postRetentionCommandTrigger:
+		if len(fields) > 1 {
+			return c.executeSubcommand(args, fields[1], fields[2:]), nil
+		}
 		return c.executeCommandInteractive(args), nil
 	default:
 		return &model.CommandResponse{
@@ -63,6 +162,26 @@ func (c *Handler) Handle(args *model.CommandArgs) (*model.CommandResponse, error
 	}
 }
 
+// executeSubcommand dispatches `/post-retention <subcommand> [params...]`.
+func (c *Handler) executeSubcommand(args *model.CommandArgs, subcommand string, params []string) *model.CommandResponse {
+	switch subcommand {
+	case channelSubcommand:
+		return c.executeChannelCommand(args)
+	case teamSubcommand:
+		return c.executeTeamCommand(args)
+	case excludeSubcommand:
+		return c.executeExcludeCommand(args, params)
+	case runSubcommand, cancelSubcommand, statusSubcommand, nextSubcommand, historySubcommand, scheduleSubcommand, freezeSubcommand, unfreezeSubcommand:
+		return c.executeJobCommand(args, subcommand, params)
+	default:
+		return &model.CommandResponse{
+			ResponseType: model.CommandResponseTypeEphemeral,
+			ChannelId:    args.ChannelId,
+			Text:         fmt.Sprintf("Unknown subcommand: %s", subcommand),
+		}
+	}
+}
+
 func (c *Handler) executeCommandInteractive(args *model.CommandArgs) *model.CommandResponse {
 	userSettings, err := c.kvStore.GetUserSettings(args.UserId)
 	if err != nil {
@@ -73,7 +192,12 @@ func (c *Handler) executeCommandInteractive(args *model.CommandArgs) *model.Comm
 		}
 	}
 
-	post := CreateStateMessagePost(userSettings, fmt.Sprintf("/plugins/%s", c.kvStore.GetManifest().Id), "")
+	keepReaction, err := c.retention.GetKeepReaction(args.UserId)
+	if err != nil {
+		c.client.Log.Warn("Failed to count kept posts", "userId", args.UserId, "error", err)
+	}
+
+	post := CreateStateMessagePost(userSettings, keepReaction, fmt.Sprintf("/plugins/%s", c.kvStore.GetManifest().Id), "")
 
 	return &model.CommandResponse{
 		ResponseType: model.CommandResponseTypeEphemeral,
@@ -82,23 +206,300 @@ func (c *Handler) executeCommandInteractive(args *model.CommandArgs) *model.Comm
 	}
 }
 
-func CreateStateMessagePost(userSettings kvstore.UserSettings, bundleUrl string, message string) *model.Post {
+func (c *Handler) executeChannelCommand(args *model.CommandArgs) *model.CommandResponse {
+	if !CanManageChannelPolicy(c.client, args.UserId, args.ChannelId) {
+		return &model.CommandResponse{
+			ResponseType: model.CommandResponseTypeEphemeral,
+			ChannelId:    args.ChannelId,
+			Text:         "Only channel admins can manage the channel retention policy.",
+		}
+	}
+
+	channelSettings, err := c.kvStore.GetChannelSettings(args.ChannelId)
+	if err != nil {
+		return &model.CommandResponse{
+			ResponseType: model.CommandResponseTypeEphemeral,
+			ChannelId:    args.ChannelId,
+			Text:         fmt.Sprintf("Failed to get channel settings: %s. Please contact administrator", err.Error()),
+		}
+	}
+
+	post := CreateChannelStateMessagePost(channelSettings, fmt.Sprintf("/plugins/%s", c.kvStore.GetManifest().Id), "")
+
+	return &model.CommandResponse{
+		ResponseType: model.CommandResponseTypeEphemeral,
+		ChannelId:    args.ChannelId,
+		Attachments:  post.Attachments(),
+	}
+}
+
+func (c *Handler) executeTeamCommand(args *model.CommandArgs) *model.CommandResponse {
+	if !CanManageTeamPolicy(c.client, args.UserId, args.TeamId) {
+		return &model.CommandResponse{
+			ResponseType: model.CommandResponseTypeEphemeral,
+			ChannelId:    args.ChannelId,
+			Text:         "Only team admins can manage the team retention policy.",
+		}
+	}
+
+	teamSettings, err := c.kvStore.GetTeamSettings(args.TeamId)
+	if err != nil {
+		return &model.CommandResponse{
+			ResponseType: model.CommandResponseTypeEphemeral,
+			ChannelId:    args.ChannelId,
+			Text:         fmt.Sprintf("Failed to get team settings: %s. Please contact administrator", err.Error()),
+		}
+	}
+
+	post := CreateTeamStateMessagePost(teamSettings, fmt.Sprintf("/plugins/%s", c.kvStore.GetManifest().Id), "")
+
+	return &model.CommandResponse{
+		ResponseType: model.CommandResponseTypeEphemeral,
+		ChannelId:    args.ChannelId,
+		Attachments:  post.Attachments(),
+	}
+}
+
+// executeExcludeCommand manages the user's channel exclusion list: `/post-retention exclude [add|remove|list]`.
+// add and remove apply to the current channel; add is the default action.
+func (c *Handler) executeExcludeCommand(args *model.CommandArgs, params []string) *model.CommandResponse {
+	action := excludeAddAction
+	if len(params) > 0 {
+		action = params[0]
+	}
+
+	userSettings, err := c.kvStore.GetUserSettings(args.UserId)
+	if err != nil {
+		return &model.CommandResponse{
+			ResponseType: model.CommandResponseTypeEphemeral,
+			ChannelId:    args.ChannelId,
+			Text:         fmt.Sprintf("Failed to get user settings: %s. Please contact administrator", err.Error()),
+		}
+	}
+	userSettings.UserID = args.UserId
+
+	var text string
+	switch action {
+	case excludeAddAction:
+		if !slices.Contains(userSettings.ExcludedChannelIDs, args.ChannelId) {
+			userSettings.ExcludedChannelIDs = append(userSettings.ExcludedChannelIDs, args.ChannelId)
+		}
+		text = "Your posts in this channel will never be purged by your retention policy."
+	case excludeRemoveAction:
+		userSettings.ExcludedChannelIDs = slices.DeleteFunc(userSettings.ExcludedChannelIDs, func(id string) bool {
+			return id == args.ChannelId
+		})
+		text = "Your posts in this channel are subject to your retention policy again."
+	case excludeListAction:
+		return &model.CommandResponse{
+			ResponseType: model.CommandResponseTypeEphemeral,
+			ChannelId:    args.ChannelId,
+			Text:         c.excludedChannelsText(userSettings.ExcludedChannelIDs),
+		}
+	default:
+		return &model.CommandResponse{
+			ResponseType: model.CommandResponseTypeEphemeral,
+			ChannelId:    args.ChannelId,
+			Text:         fmt.Sprintf("Unknown action: %s. Use add, remove or list.", action),
+		}
+	}
+
+	if err := c.kvStore.SetUserSettings(args.UserId, &userSettings); err != nil {
+		text = fmt.Sprintf("Failed to save user settings: %s. Please contact administrator", err.Error())
+	}
+
+	return &model.CommandResponse{
+		ResponseType: model.CommandResponseTypeEphemeral,
+		ChannelId:    args.ChannelId,
+		Text:         text,
+	}
+}
+
+func (c *Handler) excludedChannelsText(channelIDs []string) string {
+	if len(channelIDs) == 0 {
+		return "Your posts are not excluded from purging in any channel."
+	}
+
+	lines := []string{"Your posts are never purged in:"}
+	for _, channelID := range channelIDs {
+		name := channelID
+		if channel, err := c.client.Channel.Get(channelID); err == nil {
+			name = channel.DisplayName
+			if name == "" {
+				name = channel.Name
+			}
+		}
+		lines = append(lines, "- "+name)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CanManageChannelPolicy reports whether the user is allowed to change the retention policy of the channel,
+// i.e. is a channel admin (or a team/system admin, who inherit the permission).
+func CanManageChannelPolicy(client *pluginapi.Client, userID string, channelID string) bool {
+	return client.User.HasPermissionToChannel(userID, channelID, model.PermissionManageChannelRoles)
+}
+
+// CanManageTeamPolicy reports whether the user is allowed to change the default retention policy of the team,
+// i.e. is a team admin (or a system admin).
+func CanManageTeamPolicy(client *pluginapi.Client, userID string, teamID string) bool {
+	return teamID != "" && client.User.HasPermissionToTeam(userID, teamID, model.PermissionManageTeam)
+}
+
+// IsSystemAdmin reports whether the user may control the retention job.
+func IsSystemAdmin(client *pluginapi.Client, userID string) bool {
+	return client.User.HasPermissionTo(userID, model.PermissionManageSystem)
+}
+
+// UserLocation returns the Mattermost time zone of the user, UTC when it is not set or unknown.
+func UserLocation(client *pluginapi.Client, userID string) *time.Location {
+	user, err := client.User.Get(userID)
+	if err != nil {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(user.GetPreferredTimezone())
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func CreateStateMessagePost(userSettings kvstore.UserSettings, keepReaction KeepReaction, bundleUrl string, message string) *model.Post {
 	statusValue := "Inactive"
 	if userSettings.Enabled {
 		statusValue = "Active"
 	}
 
 	postAgeInDaysValue := "N/A"
-	if userSettings.Enabled && userSettings.PostAgeInDays > 0 {
+	if userSettings.Enabled && userSettings.PostAgeInHours > 0 {
+		postAgeInDaysValue = fmt.Sprintf("%g hours", userSettings.PostAgeInHours)
+	} else if userSettings.Enabled && userSettings.PostAgeInDays > 0 {
 		postAgeInDaysValue = fmt.Sprintf("%d days", int(userSettings.PostAgeInDays))
 	}
 
+	kept := []string{}
+	if !userSettings.DeletePinned {
+		kept = append(kept, "pinned")
+	}
+	if !userSettings.DeleteSaved {
+		kept = append(kept, "saved")
+	}
+	keptValue := "None"
+	if len(kept) > 0 {
+		keptValue = strings.Join(kept, ", ") + " posts"
+	}
+
+	channelTypesValue := "All"
+	if len(userSettings.ChannelTypes) > 0 {
+		names := []string{}
+		for _, option := range ChannelTypeOptions {
+			if userSettings.IncludesChannelType(option.Type) {
+				names = append(names, option.DisplayName)
+			}
+		}
+		channelTypesValue = strings.Join(names, ", ")
+	}
+
+	excludedChannelsValue := "None"
+	if len(userSettings.ExcludedChannelIDs) > 0 {
+		excludedChannelsValue = fmt.Sprintf("%d channels", len(userSettings.ExcludedChannelIDs))
+	}
+
+	fields := []*model.SlackAttachmentField{
+		{
+			Title: "Status",
+			Value: statusValue,
+			Short: true,
+		},
+		{
+			Title: "Remove posts after",
+			Value: postAgeInDaysValue,
+			Short: true,
+		},
+		{
+			Title: "Always kept",
+			Value: keptValue,
+			Short: true,
+		},
+		{
+			Title: "Age measured from",
+			Value: store.AgeBasisFromString(userSettings.AgeBasis).DisplayName(),
+			Short: true,
+		},
+		{
+			Title: "Threads",
+			Value: store.ThreadModeFromString(userSettings.ThreadMode).DisplayName(),
+			Short: true,
+		},
+		{
+			Title: "Channel types",
+			Value: channelTypesValue,
+			Short: true,
+		},
+		{
+			Title: "Excluded channels",
+			Value: excludedChannelsValue,
+			Short: true,
+		},
+	}
+
+	if keepReaction.EmojiName != "" {
+		fields = append(fields, &model.SlackAttachmentField{
+			Title: fmt.Sprintf("Protected by :%s:", keepReaction.EmojiName),
+			Value: fmt.Sprintf("%d posts", keepReaction.Count),
+			Short: true,
+		})
+	}
+
 	post := &model.Post{
 		Type: model.PostTypeEphemeral,
 	}
 	post.SetProps(model.StringInterface{
 		"attachments": []*model.SlackAttachment{{
-			Title: "Posts retention policy",
+			Title:  "Posts retention policy",
+			Text:   message,
+			Fields: fields,
+			Actions: []*model.PostAction{{
+				Integration: &model.PostActionIntegration{
+					URL: fmt.Sprintf("%s/api/v1/actions/settings", bundleUrl),
+				},
+				Type: model.PostActionTypeButton,
+				Name: "Settings",
+			}},
+		}},
+	})
+
+	return post
+}
+
+func CreateChannelStateMessagePost(channelSettings kvstore.ChannelSettings, bundleUrl string, message string) *model.Post {
+	return createPolicyStateMessagePost("Channel posts retention policy", "Remove all channel posts after",
+		channelSettings.Enabled, channelSettings.PostAgeInDays, channelSettings.ThreadMode, channelSettings.AgeBasis, fmt.Sprintf("%s/api/v1/actions/channel-settings", bundleUrl), message)
+}
+
+func CreateTeamStateMessagePost(teamSettings kvstore.TeamSettings, bundleUrl string, message string) *model.Post {
+	return createPolicyStateMessagePost("Team posts retention policy", "Remove members' posts after",
+		teamSettings.Enabled, teamSettings.PostAgeInDays, teamSettings.ThreadMode, teamSettings.AgeBasis, fmt.Sprintf("%s/api/v1/actions/team-settings", bundleUrl), message)
+}
+
+// createPolicyStateMessagePost renders the state card of a channel or team policy with a button opening its settings dialog.
+func createPolicyStateMessagePost(title string, ageTitle string, enabled bool, postAgeInDays float64, threadMode string, ageBasis string, actionURL string, message string) *model.Post {
+	statusValue := "Inactive"
+	if enabled {
+		statusValue = "Active"
+	}
+
+	postAgeInDaysValue := "N/A"
+	if enabled && postAgeInDays > 0 {
+		postAgeInDaysValue = fmt.Sprintf("%d days", int(postAgeInDays))
+	}
+
+	post := &model.Post{
+		Type: model.PostTypeEphemeral,
+	}
+	post.SetProps(model.StringInterface{
+		"attachments": []*model.SlackAttachment{{
+			Title: title,
 			Text:  message,
 			Fields: []*model.SlackAttachmentField{
 				{
@@ -107,14 +508,24 @@ func CreateStateMessagePost(userSettings kvstore.UserSettings, bundleUrl string,
 					Short: true,
 				},
 				{
-					Title: "Remove posts after",
+					Title: ageTitle,
 					Value: postAgeInDaysValue,
 					Short: true,
 				},
+				{
+					Title: "Age measured from",
+					Value: store.AgeBasisFromString(ageBasis).DisplayName(),
+					Short: true,
+				},
+				{
+					Title: "Threads",
+					Value: store.ThreadModeFromString(threadMode).DisplayName(),
+					Short: true,
+				},
 			},
 			Actions: []*model.PostAction{{
 				Integration: &model.PostActionIntegration{
-					URL: fmt.Sprintf("%s/api/v1/actions/settings", bundleUrl),
+					URL: actionURL,
 				},
 				Type: model.PostActionTypeButton,
 				Name: "Settings",
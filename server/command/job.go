@@ -0,0 +1,243 @@
+package command
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/config"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store/kvstore"
+)
+
+// historyCommandRuns is the number of runs listed by the history command; the admin API returns them all.
+const historyCommandRuns = 20
+
+const (
+	// DefaultUpcomingRuns and MaxUpcomingRuns bound the runs listed by the schedule command and the admin API.
+	DefaultUpcomingRuns = 5
+	MaxUpcomingRuns     = 50
+
+	// scheduleLayout shows the day of week, to check it against the schedule settings.
+	scheduleLayout = "Mon " + config.FullLayout
+)
+
+// executeJobCommand controls the retention job: `/post-retention run [--dry-run]`, `cancel`, `status`, `next`,
+// `history`, `schedule [count]`, `freeze <until> [reason]` and `unfreeze`.
+func (c *Handler) executeJobCommand(args *model.CommandArgs, subcommand string, params []string) *model.CommandResponse {
+	if !IsSystemAdmin(c.client, args.UserId) {
+		return &model.CommandResponse{
+			ResponseType: model.CommandResponseTypeEphemeral,
+			ChannelId:    args.ChannelId,
+			Text:         "Only system admins can control the retention job.",
+		}
+	}
+
+	var text string
+	switch subcommand {
+	case runSubcommand:
+		text = c.runJobText(slices.Contains(params, dryRunFlag))
+	case cancelSubcommand:
+		cancelled, err := c.retention.CancelRun()
+		switch {
+		case err != nil:
+			text = fmt.Sprintf("Failed to cancel the retention run: %s.", err.Error())
+		case !cancelled:
+			text = "No retention run is in progress."
+		default:
+			text = "Cancellation of the retention run was requested. The posts left over are processed by the next scheduled run."
+		}
+	case statusSubcommand:
+		status, err := c.retention.GetRunStatus()
+		if err != nil {
+			text = fmt.Sprintf("Failed to get the retention run status: %s.", err.Error())
+		} else {
+			text = RunStatusText(status)
+		}
+		if freeze, err := c.kvStore.GetFreeze(); err == nil && freeze.Active(model.GetMillis()) {
+			text += "\n" + FreezeText(freeze)
+		}
+	case nextSubcommand:
+		next, err := c.retention.GetNextRun()
+		switch {
+		case err != nil:
+			text = fmt.Sprintf("Failed to get the next retention run: %s.", err.Error())
+		case next.IsZero():
+			text = "The retention job is not scheduled: it is disabled in the plugin settings."
+		default:
+			text = fmt.Sprintf("The retention job runs next on %s.", formatTime(next))
+		}
+	case historySubcommand:
+		history, err := c.kvStore.GetRunHistory()
+		if err != nil {
+			text = fmt.Sprintf("Failed to get the retention run history: %s.", err.Error())
+		} else {
+			text = RunHistoryText(history)
+		}
+	case scheduleSubcommand:
+		text = c.scheduleText(args.UserId, params)
+	case freezeSubcommand:
+		text = c.freezeText(args.UserId, params)
+	case unfreezeSubcommand:
+		text = "The retention job is no longer frozen. Blackout dates in the plugin settings still apply."
+		if err := c.retention.Unfreeze(args.UserId); err != nil {
+			text = fmt.Sprintf("Failed to unfreeze the retention job: %s.", err.Error())
+		}
+	}
+
+	return &model.CommandResponse{
+		ResponseType: model.CommandResponseTypeEphemeral,
+		ChannelId:    args.ChannelId,
+		Text:         text,
+	}
+}
+
+func (c *Handler) runJobText(dryRun bool) string {
+	if err := c.retention.RunNow(dryRun); err != nil {
+		return fmt.Sprintf("Failed to start the retention run: %s.", err.Error())
+	}
+	if dryRun {
+		return "Retention dry run started. Use `/post-retention status` to follow it."
+	}
+	return "Retention run started. Use `/post-retention status` to follow it."
+}
+
+func (c *Handler) scheduleText(userID string, params []string) string {
+	count := DefaultUpcomingRuns
+	if len(params) > 0 {
+		n, err := config.ParseInt(params[0], 1, MaxUpcomingRuns)
+		if err != nil {
+			return fmt.Sprintf("The number of runs must be between 1 and %d.", MaxUpcomingRuns)
+		}
+		count = n
+	}
+
+	runs, err := c.retention.GetUpcomingRuns(count)
+	if err != nil {
+		return fmt.Sprintf("Failed to compute the retention schedule: %s.", err.Error())
+	}
+	return UpcomingRunsText(runs, UserLocation(c.client, userID))
+}
+
+// UpcomingRunsText lists the next runs as a markdown table, in the time zone of the schedule and in userLoc.
+func UpcomingRunsText(runs []time.Time, userLoc *time.Location) string {
+	if len(runs) == 0 {
+		return "The retention job is not scheduled: it is disabled in the plugin settings."
+	}
+
+	lines := []string{
+		fmt.Sprintf("Next %d retention runs:", len(runs)),
+		"",
+		fmt.Sprintf("| # | Schedule (%s) | You (%s) |", ZoneName(runs[0]), ZoneName(runs[0].In(userLoc))),
+		"|---|----------|-----|",
+	}
+	for i, run := range runs {
+		lines = append(lines, fmt.Sprintf("| %d | %s | %s |", i+1, run.Format(scheduleLayout), run.In(userLoc).Format(scheduleLayout)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ZoneName returns the name of the time zone of t, or its offset for a time of day given with a fixed
+// offset, which time.Parse may resolve to the server's local time zone.
+func ZoneName(t time.Time) string {
+	if name := t.Location().String(); name != "" && t.Location() != time.Local {
+		return name
+	}
+	return t.Format("-0700")
+}
+
+func (c *Handler) freezeText(userID string, params []string) string {
+	if len(params) == 0 {
+		return "Give the end of the freeze: a duration such as `12h` or `3d`, or the last frozen date such as `2006-01-02`."
+	}
+
+	until, err := c.retention.Freeze(params[0], strings.Join(params[1:], " "), userID)
+	if until.IsZero() {
+		return fmt.Sprintf("Failed to freeze the retention job: %s.", err.Error())
+	}
+	text := fmt.Sprintf("The retention job is frozen until %s. No posts are deleted until then.", formatTime(until))
+	if err != nil {
+		text += fmt.Sprintf(" Failed to cancel the run in progress: %s.", err.Error())
+	}
+	return text
+}
+
+// FreezeText describes an active freeze for the status command.
+func FreezeText(freeze *kvstore.Freeze) string {
+	text := fmt.Sprintf("The retention job is frozen until %s (set on %s).", formatMillis(freeze.Until), formatMillis(freeze.SetAt))
+	if freeze.Reason != "" {
+		text += " Reason: " + freeze.Reason
+	}
+	return text
+}
+
+// RunStatusText describes a retention run for the status command.
+func RunStatusText(status *kvstore.RunStatus) string {
+	if status == nil {
+		return "The retention job has not run yet."
+	}
+
+	kind := status.Trigger
+	if status.DryRun {
+		kind += ", dry run"
+	}
+	counts := fmt.Sprintf("%d deleted, %d failed, %d skipped", status.PostsDeleted, status.PostsFailed, status.PostsSkipped)
+
+	now := model.GetMillis()
+	if status.Running(now) {
+		return fmt.Sprintf("Retention run `%s` (%s) is running on %s since %s. Posts so far: %s.",
+			status.RunID, kind, status.NodeID, formatMillis(status.StartedAt), counts)
+	}
+	if status.Interrupted(now) {
+		return fmt.Sprintf("Retention run `%s` (%s) started on %s at %s was interrupted: the node stopped reporting it at %s. Posts so far: %s.",
+			status.RunID, kind, status.NodeID, formatMillis(status.StartedAt), formatMillis(max(status.HeartbeatAt, status.StartedAt)), counts)
+	}
+
+	exit := status.ExitReason
+	if status.ExitDetail != "" {
+		exit += ", " + status.ExitDetail
+	}
+	return fmt.Sprintf("Last retention run `%s` (%s) ran on %s from %s to %s. Status: %s. Posts: %s.",
+		status.RunID, kind, status.NodeID, formatMillis(status.StartedAt), formatMillis(status.FinishedAt), exit, counts)
+}
+
+// RunHistoryText lists the last retention runs, newest first, as a markdown table.
+func RunHistoryText(history []kvstore.RunStatus) string {
+	if len(history) == 0 {
+		return "The retention job has not run yet."
+	}
+	if len(history) > historyCommandRuns {
+		history = history[:historyCommandRuns]
+	}
+
+	lines := []string{
+		fmt.Sprintf("Last %d retention runs:", len(history)),
+		"",
+		"| Run | Trigger | Node | Started | Finished | Status | Deleted | Failed | Skipped | Errors |",
+		"|-----|---------|------|---------|----------|--------|---------|--------|---------|--------|",
+	}
+	for _, run := range history {
+		trigger := run.Trigger
+		if run.DryRun {
+			trigger += " (dry run)"
+		}
+		exit := run.ExitReason
+		if run.ExitDetail != "" {
+			exit += ", " + run.ExitDetail
+		}
+		lines = append(lines, fmt.Sprintf("| `%s` | %s | %s | %s | %s | %s | %d | %d | %d | %d |",
+			run.RunID, trigger, run.NodeID, formatMillis(run.StartedAt), formatMillis(run.FinishedAt), exit,
+			run.PostsDeleted, run.PostsFailed, run.PostsSkipped, len(run.Errors)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatMillis(millis int64) string {
+	return formatTime(time.UnixMilli(millis))
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(config.FullLayout)
+}
@@ -0,0 +1,320 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store/kvstore"
+)
+
+// fakeRetention serves canned answers of the retention job.
+type fakeRetention struct {
+	Retention
+
+	cancelled bool
+	cancelErr error
+	status    *kvstore.RunStatus
+	statusErr error
+	runs      []time.Time
+	runsErr   error
+	// count is the number of runs asked for by the last GetUpcomingRuns call
+	count int
+}
+
+func (r *fakeRetention) CancelRun() (bool, error) {
+	return r.cancelled, r.cancelErr
+}
+
+func (r *fakeRetention) GetRunStatus() (*kvstore.RunStatus, error) {
+	return r.status, r.statusErr
+}
+
+func (r *fakeRetention) GetUpcomingRuns(count int) ([]time.Time, error) {
+	r.count = count
+	return r.runs, r.runsErr
+}
+
+// fakeKVStore serves the freeze and the run history.
+type fakeKVStore struct {
+	kvstore.KVStore
+
+	freeze     *kvstore.Freeze
+	history    []kvstore.RunStatus
+	historyErr error
+}
+
+func (s *fakeKVStore) GetFreeze() (*kvstore.Freeze, error) {
+	return s.freeze, nil
+}
+
+func (s *fakeKVStore) GetRunHistory() ([]kvstore.RunStatus, error) {
+	return s.history, s.historyErr
+}
+
+// executeJob runs a job subcommand as a system admin and returns the response text.
+func executeJob(t *testing.T, retention Retention, kvStore kvstore.KVStore, subcommand string, params ...string) string {
+	api := &plugintest.API{}
+	api.On("HasPermissionTo", "admin", model.PermissionManageSystem).Return(true)
+	defer api.AssertExpectations(t)
+
+	handler := &Handler{client: pluginapi.NewClient(api, nil), kvStore: kvStore, retention: retention}
+	response := handler.executeJobCommand(&model.CommandArgs{UserId: "admin", ChannelId: "channel1"}, subcommand, params)
+	assert.Equal(t, model.CommandResponseTypeEphemeral, response.ResponseType)
+	assert.Equal(t, "channel1", response.ChannelId)
+	return response.Text
+}
+
+func TestExecuteJobCommandAdminsOnly(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("HasPermissionTo", "user1", model.PermissionManageSystem).Return(false)
+	defer api.AssertExpectations(t)
+
+	handler := &Handler{client: pluginapi.NewClient(api, nil), kvStore: &fakeKVStore{}, retention: &fakeRetention{cancelled: true}}
+	response := handler.executeJobCommand(&model.CommandArgs{UserId: "user1"}, cancelSubcommand, nil)
+	assert.Equal(t, "Only system admins can control the retention job.", response.Text)
+}
+
+func TestCancelCommand(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		retention *fakeRetention
+		text      string
+	}{
+		{
+			name:      "run in progress",
+			retention: &fakeRetention{cancelled: true},
+			text:      "Cancellation of the retention run was requested. The posts left over are processed by the next scheduled run.",
+		},
+		{
+			name:      "no run in progress",
+			retention: &fakeRetention{},
+			text:      "No retention run is in progress.",
+		},
+		{
+			name:      "failure",
+			retention: &fakeRetention{cancelled: true, cancelErr: errors.New("cannot notify the other nodes: boom")},
+			text:      "Failed to cancel the retention run: cannot notify the other nodes: boom.",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.text, executeJob(t, tc.retention, &fakeKVStore{}, cancelSubcommand))
+		})
+	}
+}
+
+func TestStatusCommand(t *testing.T) {
+	now := model.GetMillis()
+	startedAt := now - time.Hour.Milliseconds()
+	heartbeatAt := now - 10*time.Minute.Milliseconds()
+	finishedAt := now - 30*time.Minute.Milliseconds()
+
+	for _, tc := range []struct {
+		name      string
+		retention *fakeRetention
+		freeze    *kvstore.Freeze
+		text      string
+	}{
+		{
+			name:      "never ran",
+			retention: &fakeRetention{},
+			text:      "The retention job has not run yet.",
+		},
+		{
+			name: "running",
+			retention: &fakeRetention{status: &kvstore.RunStatus{
+				RunID: "run1", Trigger: "scheduled", NodeID: "node1", StartedAt: startedAt, HeartbeatAt: now, PostsDeleted: 12, PostsFailed: 1,
+			}},
+			text: fmt.Sprintf("Retention run `run1` (scheduled) is running on node1 since %s. Posts so far: 12 deleted, 1 failed, 0 skipped.", formatMillis(startedAt)),
+		},
+		{
+			name: "node went down",
+			retention: &fakeRetention{status: &kvstore.RunStatus{
+				RunID: "run1", Trigger: "manual", NodeID: "node1", StartedAt: startedAt, HeartbeatAt: heartbeatAt, PostsDeleted: 12,
+			}},
+			text: fmt.Sprintf("Retention run `run1` (manual) started on node1 at %s was interrupted: the node stopped reporting it at %s. Posts so far: 12 deleted, 0 failed, 0 skipped.",
+				formatMillis(startedAt), formatMillis(heartbeatAt)),
+		},
+		{
+			name: "finished",
+			retention: &fakeRetention{status: &kvstore.RunStatus{
+				RunID: "run1", Trigger: "scheduled", DryRun: true, NodeID: "node1", StartedAt: startedAt, FinishedAt: finishedAt,
+				ExitReason: "canceled", ExitDetail: "window closed", PostsDeleted: 40, PostsSkipped: 3,
+			}},
+			text: fmt.Sprintf("Last retention run `run1` (scheduled, dry run) ran on node1 from %s to %s. Status: canceled, window closed. Posts: 40 deleted, 0 failed, 3 skipped.",
+				formatMillis(startedAt), formatMillis(finishedAt)),
+		},
+		{
+			name:      "frozen",
+			retention: &fakeRetention{},
+			freeze:    &kvstore.Freeze{Until: now + time.Hour.Milliseconds(), SetAt: startedAt, Reason: "audit"},
+			text: fmt.Sprintf("The retention job has not run yet.\nThe retention job is frozen until %s (set on %s). Reason: audit",
+				formatMillis(now+time.Hour.Milliseconds()), formatMillis(startedAt)),
+		},
+		{
+			name:      "freeze ended",
+			retention: &fakeRetention{},
+			freeze:    &kvstore.Freeze{Until: finishedAt, SetAt: startedAt},
+			text:      "The retention job has not run yet.",
+		},
+		{
+			name:      "failure",
+			retention: &fakeRetention{statusErr: errors.New("boom")},
+			text:      "Failed to get the retention run status: boom.",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.text, executeJob(t, tc.retention, &fakeKVStore{freeze: tc.freeze}, statusSubcommand))
+		})
+	}
+}
+
+func TestHistoryCommand(t *testing.T) {
+	startedAt := time.Date(2024, 5, 6, 1, 0, 0, 0, time.UTC).UnixMilli()
+	finishedAt := time.Date(2024, 5, 6, 1, 30, 0, 0, time.UTC).UnixMilli()
+
+	t.Run("never ran", func(t *testing.T) {
+		assert.Equal(t, "The retention job has not run yet.", executeJob(t, &fakeRetention{}, &fakeKVStore{}, historySubcommand))
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		text := executeJob(t, &fakeRetention{}, &fakeKVStore{historyErr: errors.New("boom")}, historySubcommand)
+		assert.Equal(t, "Failed to get the retention run history: boom.", text)
+	})
+
+	t.Run("runs", func(t *testing.T) {
+		history := []kvstore.RunStatus{
+			{
+				RunID: "run2", Trigger: "manual", DryRun: true, NodeID: "node1", StartedAt: startedAt, FinishedAt: finishedAt,
+				ExitReason: "completed normally", PostsDeleted: 5,
+			},
+			{
+				RunID: "run1", Trigger: "scheduled", NodeID: "node2", StartedAt: startedAt, FinishedAt: finishedAt,
+				ExitReason: "error", PostsFailed: 2, Errors: []string{"cannot create the deletion backend: boom", "post post1: boom"},
+			},
+		}
+
+		text := executeJob(t, &fakeRetention{}, &fakeKVStore{history: history}, historySubcommand)
+		assert.Equal(t, strings.Join([]string{
+			"Last 2 retention runs:",
+			"",
+			"| Run | Trigger | Node | Started | Finished | Status | Deleted | Failed | Skipped | Errors |",
+			"|-----|---------|------|---------|----------|--------|---------|--------|---------|--------|",
+			"| `run2` | manual (dry run) | node1 | May 6, 2024 1:00am +0000 | May 6, 2024 1:30am +0000 | completed normally | 5 | 0 | 0 | 0 |",
+			"| `run1` | scheduled | node2 | May 6, 2024 1:00am +0000 | May 6, 2024 1:30am +0000 | error | 0 | 2 | 0 | 2 |",
+		}, "\n"), text)
+	})
+
+	t.Run("last runs only", func(t *testing.T) {
+		history := make([]kvstore.RunStatus, historyCommandRuns+5)
+		for i := range history {
+			history[i] = kvstore.RunStatus{RunID: fmt.Sprintf("run%d", i), StartedAt: startedAt, FinishedAt: finishedAt}
+		}
+
+		lines := strings.Split(executeJob(t, &fakeRetention{}, &fakeKVStore{history: history}, historySubcommand), "\n")
+		assert.Equal(t, fmt.Sprintf("Last %d retention runs:", historyCommandRuns), lines[0])
+		assert.Len(t, lines, historyCommandRuns+4)
+		assert.Contains(t, lines[len(lines)-1], fmt.Sprintf("`run%d`", historyCommandRuns-1))
+	})
+}
+
+func TestUpcomingRunsText(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	t.Run("disabled", func(t *testing.T) {
+		assert.Equal(t, "The retention job is not scheduled: it is disabled in the plugin settings.", UpcomingRunsText(nil, newYork))
+	})
+
+	t.Run("time zone of the schedule and of the user", func(t *testing.T) {
+		runs := []time.Time{
+			time.Date(2024, 3, 29, 1, 0, 0, 0, berlin),
+			// across the daylight saving transition of Europe/Berlin
+			time.Date(2024, 4, 1, 1, 0, 0, 0, berlin),
+		}
+		assert.Equal(t, strings.Join([]string{
+			"Next 2 retention runs:",
+			"",
+			"| # | Schedule (Europe/Berlin) | You (America/New_York) |",
+			"|---|----------|-----|",
+			"| 1 | Fri Mar 29, 2024 1:00am +0100 | Thu Mar 28, 2024 8:00pm -0400 |",
+			"| 2 | Mon Apr 1, 2024 1:00am +0200 | Sun Mar 31, 2024 7:00pm -0400 |",
+		}, "\n"), UpcomingRunsText(runs, newYork))
+	})
+
+	t.Run("fixed offset", func(t *testing.T) {
+		runs := []time.Time{time.Date(2024, 3, 29, 1, 0, 0, 0, time.FixedZone("", 2*60*60))}
+		lines := strings.Split(UpcomingRunsText(runs, time.UTC), "\n")
+		assert.Equal(t, "| # | Schedule (+0200) | You (UTC) |", lines[2])
+		assert.Equal(t, "| 1 | Fri Mar 29, 2024 1:00am +0200 | Thu Mar 28, 2024 11:00pm +0000 |", lines[4])
+	})
+}
+
+func TestScheduleCommand(t *testing.T) {
+	runs := []time.Time{
+		time.Date(2024, 5, 6, 1, 0, 0, 0, time.UTC),
+		time.Date(2024, 5, 8, 1, 0, 0, 0, time.UTC),
+	}
+
+	for _, tc := range []struct {
+		name      string
+		params    []string
+		retention *fakeRetention
+		count     int
+		text      string
+	}{
+		{
+			name:      "default count",
+			retention: &fakeRetention{runs: runs},
+			count:     DefaultUpcomingRuns,
+			text:      UpcomingRunsText(runs, time.UTC),
+		},
+		{
+			name:      "count",
+			params:    []string{"2"},
+			retention: &fakeRetention{runs: runs},
+			count:     2,
+			text:      UpcomingRunsText(runs, time.UTC),
+		},
+		{
+			name:      "count out of range",
+			params:    []string{fmt.Sprint(MaxUpcomingRuns + 1)},
+			retention: &fakeRetention{runs: runs},
+			text:      fmt.Sprintf("The number of runs must be between 1 and %d.", MaxUpcomingRuns),
+		},
+		{
+			name:      "count not a number",
+			params:    []string{"all"},
+			retention: &fakeRetention{runs: runs},
+			text:      fmt.Sprintf("The number of runs must be between 1 and %d.", MaxUpcomingRuns),
+		},
+		{
+			name:      "failure",
+			retention: &fakeRetention{runsErr: errors.New("boom")},
+			count:     DefaultUpcomingRuns,
+			text:      "Failed to compute the retention schedule: boom.",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			api := &plugintest.API{}
+			api.On("HasPermissionTo", "admin", model.PermissionManageSystem).Return(true)
+			api.On("GetUser", "admin").Return(&model.User{Id: "admin"}, nil).Maybe()
+			defer api.AssertExpectations(t)
+
+			handler := &Handler{client: pluginapi.NewClient(api, nil), kvStore: &fakeKVStore{}, retention: tc.retention}
+			response := handler.executeJobCommand(&model.CommandArgs{UserId: "admin"}, scheduleSubcommand, tc.params)
+			assert.Equal(t, tc.text, response.Text)
+			assert.Equal(t, tc.count, tc.retention.count)
+		})
+	}
+}
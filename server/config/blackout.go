@@ -0,0 +1,152 @@
+package config
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// BlackoutDateLayout is the layout of the dates of blackout ranges and freezes.
+	BlackoutDateLayout = "2006-01-02"
+
+	blackoutRangeSeparator = ".."
+)
+
+var (
+	ErrInvalidBlackoutDates = errors.New("invalid blackout dates")
+	ErrInvalidFreezeUntil   = errors.New("invalid freeze end")
+)
+
+// BlackoutPeriod is a period in which the retention job does not run, from Start up to End excluded.
+type BlackoutPeriod struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Contains reports whether t is within the period.
+func (b BlackoutPeriod) Contains(t time.Time) bool {
+	return !t.Before(b.Start) && t.Before(b.End)
+}
+
+// ParseBlackoutDates parses a list of dates and inclusive date ranges separated by commas or new
+// lines, such as "2024-12-24..2024-12-26, 2025-01-01". The days start and end at midnight in loc.
+func ParseBlackoutDates(s string, loc *time.Location) ([]BlackoutPeriod, error) {
+	var periods []BlackoutPeriod
+	for _, item := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' }) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		first, last, isRange := strings.Cut(item, blackoutRangeSeparator)
+		if !isRange {
+			last = first
+		}
+		start, err := time.ParseInLocation(BlackoutDateLayout, strings.TrimSpace(first), loc)
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidBlackoutDates, "'%s' is not a date or a date range", item)
+		}
+		end, err := time.ParseInLocation(BlackoutDateLayout, strings.TrimSpace(last), loc)
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidBlackoutDates, "'%s' is not a date or a date range", item)
+		}
+		if end.Before(start) {
+			return nil, errors.Wrapf(ErrInvalidBlackoutDates, "'%s' ends before it starts", item)
+		}
+
+		periods = append(periods, BlackoutPeriod{Start: start, End: nextDay(end)})
+	}
+	return periods, nil
+}
+
+// ParseFreezeUntil parses the end of a freeze: a duration from now such as "12h" or "3d", or a date
+// frozen until its end in loc.
+func ParseFreezeUntil(s string, now time.Time, loc *time.Location) (time.Time, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := ParseInt(days, 1, MaxIntervalDays)
+		if err != nil {
+			return time.Time{}, errors.Wrapf(ErrInvalidFreezeUntil, "'%s' is not a number of days: %s", s, err)
+		}
+		return now.AddDate(0, 0, n), nil
+	}
+
+	if d, err := time.ParseDuration(s); err == nil {
+		if d <= 0 {
+			return time.Time{}, errors.Wrapf(ErrInvalidFreezeUntil, "'%s' is not a positive duration", s)
+		}
+		return now.Add(d), nil
+	}
+
+	date, err := time.ParseInLocation(BlackoutDateLayout, s, loc)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(ErrInvalidFreezeUntil, "'%s' is neither a duration nor a date", s)
+	}
+	until := nextDay(date)
+	if !until.After(now) {
+		return time.Time{}, errors.Wrapf(ErrInvalidFreezeUntil, "'%s' is in the past", s)
+	}
+	return until, nil
+}
+
+// FrozenUntil returns when the period frozen at t ends, or the zero time when t is not frozen. A time
+// is frozen within a blackout period or before freezeUntil; adjacent periods are joined.
+func (c *RetentionJobSettings) FrozenUntil(t time.Time, freezeUntil time.Time) time.Time {
+	until := t
+	for moved := true; moved; {
+		moved = false
+		if until.Before(freezeUntil) {
+			until = freezeUntil
+			moved = true
+		}
+		for _, period := range c.Blackouts {
+			if period.Contains(until) {
+				until = period.End
+				moved = true
+			}
+		}
+	}
+
+	if until.Equal(t) {
+		return time.Time{}
+	}
+	return until
+}
+
+// NextUnfrozenRun returns the next scheduled run after last, skipping the runs in frozen periods.
+func (c *RetentionJobSettings) NextUnfrozenRun(last time.Time, freezeUntil time.Time) time.Time {
+	next := c.NextRun(last)
+	for {
+		// a cron expression that never matches has no next run
+		if next.IsZero() {
+			return next
+		}
+		until := c.FrozenUntil(next, freezeUntil)
+		if until.IsZero() {
+			return next
+		}
+		next = c.runAtOrAfter(next, until)
+	}
+}
+
+// runAtOrAfter returns the first scheduled run at or after t, for a schedule going through run. Weekly,
+// every n days and monthly after_last_run schedules count from the last run, so their runs are followed
+// from run rather than from t, which would shift them.
+func (c *RetentionJobSettings) runAtOrAfter(run time.Time, t time.Time) time.Time {
+	switch {
+	case c.Frequency == Weekly, c.Frequency == EveryNDays,
+		c.Frequency == Monthly && c.Monthly.Mode == MonthlyAfterLastRun:
+		for !run.IsZero() && run.Before(t) {
+			run = c.NextRun(run)
+		}
+		return run
+	default:
+		return c.NextRun(t.Add(-time.Nanosecond))
+	}
+}
+
+// nextDay returns midnight of the day after the date.
+func nextDay(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day()+1, 0, 0, 0, 0, date.Location())
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBlackoutDates(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	periods, err := ParseBlackoutDates("2024-12-24..2024-12-26,\n 2025-01-01 ", berlin)
+	require.NoError(t, err)
+	require.Len(t, periods, 2)
+	assert.Equal(t, time.Date(2024, 12, 24, 0, 0, 0, 0, berlin), periods[0].Start)
+	assert.Equal(t, time.Date(2024, 12, 27, 0, 0, 0, 0, berlin), periods[0].End)
+	assert.Equal(t, time.Date(2025, 1, 2, 0, 0, 0, 0, berlin), periods[1].End)
+
+	periods, err = ParseBlackoutDates("", berlin)
+	require.NoError(t, err)
+	assert.Empty(t, periods)
+
+	for _, invalid := range []string{"2024-12-26..2024-12-24", "christmas", "2024-12-24..", "2024-13-01"} {
+		_, err = ParseBlackoutDates(invalid, berlin)
+		assert.ErrorIs(t, err, ErrInvalidBlackoutDates, invalid)
+	}
+}
+
+func TestParseFreezeUntil(t *testing.T) {
+	now := time.Date(2024, 5, 10, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		until   string
+		want    time.Time
+		wantErr bool
+	}{
+		{until: "12h", want: now.Add(12 * time.Hour)},
+		{until: "90m", want: now.Add(90 * time.Minute)},
+		{until: "3d", want: time.Date(2024, 5, 13, 9, 30, 0, 0, time.UTC)},
+		{until: "2024-05-12", want: time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)},
+		{until: "2024-05-10", want: time.Date(2024, 5, 11, 0, 0, 0, 0, time.UTC)},
+		{until: "2024-05-09", wantErr: true},
+		{until: "-1h", wantErr: true},
+		{until: "0d", wantErr: true},
+		{until: "soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.until, func(t *testing.T) {
+			got, err := ParseFreezeUntil(tt.until, now, time.UTC)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidFreezeUntil)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tt.want.Equal(got), "want %s, got %s", tt.want, got)
+		})
+	}
+}
+
+func TestNextUnfrozenRun(t *testing.T) {
+	timeOfDay, err := parseTimeOfDay("1:00am +0000", nil)
+	require.NoError(t, err)
+	blackouts, err := ParseBlackoutDates("2024-12-24..2024-12-26, 2024-12-27", time.UTC)
+	require.NoError(t, err)
+
+	settings := &RetentionJobSettings{Frequency: Daily, TimeOfDay: timeOfDay, Blackouts: blackouts}
+	date := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 0, 0, 0, time.UTC)
+	}
+	// schedules counting from the last run, with a blackout on their next run
+	withBlackout := func(settings RetentionJobSettings, dates string) *RetentionJobSettings {
+		settings.TimeOfDay = timeOfDay
+		settings.Blackouts, err = ParseBlackoutDates(dates, time.UTC)
+		require.NoError(t, err)
+		return &settings
+	}
+	weekly := withBlackout(RetentionJobSettings{Frequency: Weekly, DayOfWeek: 1}, "2024-05-13..2024-05-15")
+	everyThreeDays := withBlackout(RetentionJobSettings{Frequency: EveryNDays, Interval: 3}, "2024-05-04..2024-05-05")
+	monthly := withBlackout(RetentionJobSettings{Frequency: Monthly, DayOfWeek: 1, Monthly: MonthlySchedule{Mode: MonthlyAfterLastRun, DayOfWeek: 1}},
+		"2024-06-10..2024-06-20")
+
+	tests := []struct {
+		name        string
+		settings    *RetentionJobSettings
+		last        time.Time
+		freezeUntil time.Time
+		want        time.Time
+	}{
+		{name: "not frozen", last: date(12, 20, 1), want: date(12, 21, 1)},
+		{name: "adjacent blackouts are skipped", last: date(12, 23, 1), want: date(12, 28, 1)},
+		{name: "freeze", last: date(12, 1, 1), freezeUntil: date(12, 5, 12), want: date(12, 6, 1)},
+		{name: "freeze ending in a blackout", last: date(12, 20, 1), freezeUntil: date(12, 24, 12), want: date(12, 28, 1)},
+		{name: "freeze ending at the run", last: date(12, 1, 1), freezeUntil: date(12, 5, 1), want: date(12, 5, 1)},
+		{name: "past freeze", last: date(12, 1, 1), freezeUntil: date(11, 1, 1), want: date(12, 2, 1)},
+		{name: "weekly", settings: weekly, last: date(5, 6, 1), want: date(5, 20, 1)},
+		{name: "weekly freeze", settings: weekly, last: date(5, 20, 1), freezeUntil: date(6, 4, 12), want: date(6, 10, 1)},
+		{name: "every n days", settings: everyThreeDays, last: date(5, 1, 1), want: date(5, 7, 1)},
+		{name: "every n days freeze", settings: everyThreeDays, last: date(5, 7, 1), freezeUntil: date(5, 11, 12), want: date(5, 13, 1)},
+		{name: "every n days freeze ending at the run", settings: everyThreeDays, last: date(5, 7, 1), freezeUntil: date(5, 13, 1), want: date(5, 13, 1)},
+		{name: "monthly after the last run", settings: monthly, last: date(5, 6, 1), want: date(7, 15, 1)},
+		{name: "monthly after the last run freeze", settings: monthly, last: date(7, 15, 1), freezeUntil: date(8, 25, 12), want: date(9, 23, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := settings
+			if tt.settings != nil {
+				settings = tt.settings
+			}
+			got := settings.NextUnfrozenRun(tt.last, tt.freezeUntil)
+			assert.True(t, tt.want.Equal(got), "want %s, got %s", tt.want, got)
+		})
+	}
+
+	assert.Equal(t, date(12, 28, 0), settings.FrozenUntil(date(12, 25, 9), time.Time{}))
+	assert.True(t, settings.FrozenUntil(date(12, 28, 9), time.Time{}).IsZero())
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PostAgeBounds are the admin-enforced limits for the retention period of any policy, in days.
+// A zero value means the corresponding side is unbounded.
+type PostAgeBounds struct {
+	Min float64
+	Max float64
+}
+
+// GetPostAgeBounds returns the configured bounds. A minimum that cannot be parsed falls back to the default
+// and a maximum to no upper bound; ParsePostAgeBounds reports them.
+func (c *Configuration) GetPostAgeBounds() PostAgeBounds {
+	minDays, err := c.minPostAge()
+	if err != nil {
+		minDays = DefaultMinPostAgeInDays
+	}
+	maxDays, err := c.maxPostAge()
+	if err != nil {
+		maxDays = 0
+	}
+	bounds := PostAgeBounds{Min: minDays, Max: maxDays}
+
+	// a misconfigured maximum below the minimum collapses to the minimum
+	if bounds.Max > 0 && bounds.Max < bounds.Min {
+		bounds.Max = bounds.Min
+	}
+
+	return bounds
+}
+
+// ParsePostAgeBounds parses MinPostAge and MaxPostAge, falling back to MinPostAgeInDays and
+// MaxPostAgeInDays when they are empty.
+func (c *Configuration) ParsePostAgeBounds() (PostAgeBounds, error) {
+	minDays, err := c.minPostAge()
+	if err != nil {
+		return PostAgeBounds{}, fmt.Errorf("cannot parse `Minimum retention period`: %w", err)
+	}
+	maxDays, err := c.maxPostAge()
+	if err != nil {
+		return PostAgeBounds{}, fmt.Errorf("cannot parse `Maximum retention period`: %w", err)
+	}
+	return PostAgeBounds{Min: minDays, Max: maxDays}, nil
+}
+
+func (c *Configuration) minPostAge() (float64, error) {
+	if strings.TrimSpace(c.MinPostAge) == "" {
+		return float64(max(c.MinPostAgeInDays, 0)), nil
+	}
+	return ParsePostAge(c.MinPostAge)
+}
+
+func (c *Configuration) maxPostAge() (float64, error) {
+	if strings.TrimSpace(c.MaxPostAge) == "" {
+		return float64(max(c.MaxPostAgeInDays, 0)), nil
+	}
+	return ParsePostAge(c.MaxPostAge)
+}
+
+// ParsePostAge parses a retention period into days: a number of hours with an h suffix such as "6h", or
+// a number of days with an optional d suffix such as "7d" or "1.5".
+func ParsePostAge(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	number, hoursPerUnit := strings.TrimSuffix(s, "d"), 24.0
+	if hours, ok := strings.CutSuffix(s, "h"); ok {
+		number, hoursPerUnit = hours, 1
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+		return 0, fmt.Errorf("'%s' is not a number of hours or days", s)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("'%s' must be greater than or equal to 0", s)
+	}
+	return n * hoursPerUnit / 24, nil
+}
+
+// Contains reports whether the retention period is within the bounds.
+func (b PostAgeBounds) Contains(ageInDays float64) bool {
+	return b.Clamp(ageInDays) == ageInDays
+}
+
+// Clamp limits the retention period to the bounds.
+func (b PostAgeBounds) Clamp(ageInDays float64) float64 {
+	if b.Min > 0 && ageInDays < b.Min {
+		return b.Min
+	}
+	if b.Max > 0 && ageInDays > b.Max {
+		return b.Max
+	}
+	return ageInDays
+}
+
+// String describes the allowed range for user-facing messages. Bounds shorter than a day are given in hours.
+func (b PostAgeBounds) String() string {
+	switch {
+	case b.Min >= 1 && b.Max > 0:
+		return fmt.Sprintf("between %g and %g days", b.Min, b.Max)
+	case b.Min > 0 && b.Max > 0:
+		return fmt.Sprintf("between %s and %s", formatPostAge(b.Min), formatPostAge(b.Max))
+	case b.Min > 0:
+		return "at least " + formatPostAge(b.Min)
+	case b.Max > 0:
+		return "at most " + formatPostAge(b.Max)
+	default:
+		return "any number of days"
+	}
+}
+
+// formatPostAge formats a retention period in days, or in hours when it is shorter than a day.
+func formatPostAge(days float64) string {
+	if days < 1 {
+		// rounded, since hours parsed into days do not always convert back exactly
+		return fmt.Sprintf("%g hours", math.Round(days*24*1000)/1000)
+	}
+	return fmt.Sprintf("%g days", days)
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePostAge(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    float64
+		wantErr bool
+	}{
+		{name: "days", s: "7", want: 7},
+		{name: "days with unit", s: "7d", want: 7},
+		{name: "fractional days", s: "1.5d", want: 1.5},
+		{name: "hours", s: "6h", want: 0.25},
+		{name: "hours over a day", s: "36h", want: 1.5},
+		{name: "spaces", s: " 12 h ", want: 0.5},
+		{name: "zero", s: "0", want: 0},
+		{name: "negative", s: "-1d", wantErr: true},
+		{name: "minutes", s: "30m", wantErr: true},
+		{name: "unit only", s: "h", wantErr: true},
+		{name: "not a number", s: "a week", wantErr: true},
+		{name: "infinite", s: "Inf", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePostAge(tt.s)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGetPostAgeBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Configuration
+		want    PostAgeBounds
+		wantErr bool
+	}{
+		{name: "defaults", config: *NewConfiguration(), want: PostAgeBounds{Min: 1}},
+		{name: "hours", config: Configuration{MinPostAge: "6h", MaxPostAge: "30d"}, want: PostAgeBounds{Min: 0.25, Max: 30}},
+		{name: "no lower bound", config: Configuration{MinPostAge: "0", MinPostAgeInDays: 1}, want: PostAgeBounds{}},
+		{name: "legacy days", config: Configuration{MinPostAgeInDays: 7, MaxPostAgeInDays: 90}, want: PostAgeBounds{Min: 7, Max: 90}},
+		{name: "overrides legacy days", config: Configuration{MinPostAge: "12h", MinPostAgeInDays: 7}, want: PostAgeBounds{Min: 0.5}},
+		{name: "negative legacy days", config: Configuration{MinPostAgeInDays: -1, MaxPostAgeInDays: -1}, want: PostAgeBounds{}},
+		{name: "maximum below the minimum", config: Configuration{MinPostAge: "2d", MaxPostAge: "12h"}, want: PostAgeBounds{Min: 2, Max: 2}},
+		{name: "invalid minimum", config: Configuration{MinPostAge: "soon", MaxPostAge: "30"}, want: PostAgeBounds{Min: DefaultMinPostAgeInDays, Max: 30}, wantErr: true},
+		{name: "invalid maximum", config: Configuration{MinPostAge: "6h", MaxPostAge: "-30"}, want: PostAgeBounds{Min: 0.25}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.config.GetPostAgeBounds())
+
+			_, err := tt.config.ParsePostAgeBounds()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPostAgeBounds(t *testing.T) {
+	tests := []struct {
+		name   string
+		bounds PostAgeBounds
+		age    float64
+		want   float64
+		text   string
+	}{
+		{name: "unbounded", bounds: PostAgeBounds{}, age: 0.01, want: 0.01, text: "any number of days"},
+		{name: "within", bounds: PostAgeBounds{Min: 1, Max: 30}, age: 7, want: 7, text: "between 1 and 30 days"},
+		{name: "at the minimum", bounds: PostAgeBounds{Min: 1, Max: 30}, age: 1, want: 1, text: "between 1 and 30 days"},
+		{name: "below the minimum", bounds: PostAgeBounds{Min: 1, Max: 30}, age: 0.5, want: 1, text: "between 1 and 30 days"},
+		{name: "above the maximum", bounds: PostAgeBounds{Min: 1, Max: 30}, age: 365, want: 30, text: "between 1 and 30 days"},
+		{name: "minimum in hours", bounds: PostAgeBounds{Min: 0.25, Max: 30}, age: 0.125, want: 0.25, text: "between 6 hours and 30 days"},
+		{name: "both in hours", bounds: PostAgeBounds{Min: 0.25, Max: 0.5}, age: 1, want: 0.5, text: "between 6 hours and 12 hours"},
+		{name: "minimum only", bounds: PostAgeBounds{Min: 7}, age: 400, want: 400, text: "at least 7 days"},
+		{name: "minimum only in hours", bounds: PostAgeBounds{Min: 5.0 / 24}, age: 0.1, want: 5.0 / 24, text: "at least 5 hours"},
+		{name: "maximum only", bounds: PostAgeBounds{Max: 30}, age: 31, want: 30, text: "at most 30 days"},
+		{name: "maximum only in hours", bounds: PostAgeBounds{Max: 0.75}, age: 0.5, want: 0.5, text: "at most 18 hours"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.bounds.Clamp(tt.age))
+			assert.Equal(t, tt.want == tt.age, tt.bounds.Contains(tt.age))
+			assert.Equal(t, tt.text, tt.bounds.String())
+		})
+	}
+}
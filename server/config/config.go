@@ -1,11 +1,20 @@
 package config
 
+import (
+	"strings"
+)
+
 const (
 	DefaultBatchSize = 50
 	//DefaultListBatchSize    = 1000
 
 	MinBatchSize = 10
 	MaxBatchSize = 1000
+
+	DefaultMinPostAgeInDays = 1
+
+	DefaultWorkers = 1
+	MaxWorkers     = 16
 )
 
 // Configuration captures the plugin's external configuration as exposed in the Mattermost server
@@ -23,18 +32,79 @@ type Configuration struct {
 	EnableRetentionPolicy bool
 	// Frequency is the frequency at which the plugin will run the retention policy.
 	Frequency string
+	// FrequencyInterval is the number of hours or days between runs when Frequency is every_n_hours or every_n_days.
+	FrequencyInterval int
+	// CronExpression is the five-field cron expression used when Frequency is cron.
+	CronExpression string
+	// MonthlyMode is the MonthlyMode used when Frequency is monthly.
+	MonthlyMode string
+	// DayOfMonth is the day of the month of the day_of_month monthly mode.
+	DayOfMonth int
+	// WeekOfMonth is the week of the nth_weekday monthly mode: 1 to 4 or last.
+	WeekOfMonth string
 	// DayOfWeek is the day of the week on which the plugin will run the retention policy.
 	DayOfWeek string
 	// TimeOfDay is the time of day at which the plugin will run the retention policy.
 	TimeOfDay string
+	// TimeZone is the IANA name of the time zone of TimeOfDay, such as Europe/Berlin. When empty,
+	// TimeOfDay must carry a fixed offset.
+	TimeZone string
+	// BlackoutDates lists the dates and date ranges on which the retention job does not run, in the time zone of TimeOfDay.
+	BlackoutDates string
+	// WindowEndTime is the time of day at which a running retention job is stopped. Empty means no end time.
+	WindowEndTime string
+	// MaxRunDurationMinutes is the longest a retention job may run. Zero means no limit.
+	MaxRunDurationMinutes int
 	// BatchSize is the number of posts to delete in each batch when running the retention policy.
 	BatchSize int
+	// Workers is the number of users whose posts are deleted concurrently.
+	Workers int
+	// MaxPostsPerSecond is the number of posts all workers together may delete per second. Zero means no limit.
+	MaxPostsPerSecond int
+	// MinPostAge is the shortest retention period a policy may use, such as 6h or 7d; a plain number is in days.
+	// Zero means no lower bound; empty falls back to MinPostAgeInDays.
+	MinPostAge string
+	// MaxPostAge is the longest retention period a policy may use, in the format of MinPostAge. Zero means no
+	// upper bound; empty falls back to MaxPostAgeInDays.
+	MaxPostAge string
+	// MinPostAgeInDays is the minimum in whole days of the plugin versions before MinPostAge.
+	MinPostAgeInDays int
+	// MaxPostAgeInDays is the maximum in whole days of the plugin versions before MaxPostAge.
+	MaxPostAgeInDays int
+	// DeletionBackend is how posts are deleted, one of the DeletionBackend values.
+	DeletionBackend string
+	// DeletionAPIURL is the server URL used by the http deletion backend; the SiteURL when empty.
+	DeletionAPIURL string
+	// DeletionAPIToken is the access token used by the http deletion backend.
+	DeletionAPIToken string
+	// DryRun makes the retention job report the posts it would delete instead of deleting them.
+	DryRun bool
+	// KeepReactionEmoji is the name of the emoji protecting a post from deletion when its author or an admin reacted with it.
+	KeepReactionEmoji string
 }
 
 func NewConfiguration() *Configuration {
 	return &Configuration{
-		BatchSize: DefaultBatchSize,
+		BatchSize:        DefaultBatchSize,
+		Workers:          DefaultWorkers,
+		MinPostAgeInDays: DefaultMinPostAgeInDays,
+	}
+}
+
+// GetKeepReactionEmoji returns the configured keep emoji name without surrounding colons, or an empty string when disabled.
+func (c *Configuration) GetKeepReactionEmoji() string {
+	return strings.Trim(strings.TrimSpace(c.KeepReactionEmoji), ":")
+}
+
+// GetWorkers returns the configured number of workers, limited to 1..MaxWorkers.
+func (c *Configuration) GetWorkers() int {
+	if c.Workers < 1 {
+		return DefaultWorkers
+	}
+	if c.Workers > MaxWorkers {
+		return MaxWorkers
 	}
+	return c.Workers
 }
 
 // Clone shallow copies the configuration. Your implementation may require a deep copy if
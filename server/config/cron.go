@@ -0,0 +1,202 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrInvalidCronExpression = errors.New("invalid cron expression")
+)
+
+// cronSearchYears bounds the search for the next fire time; it covers every day of the leap year cycle.
+const cronSearchYears = 5
+
+// cronField describes the values allowed in one field of a cron expression.
+type cronField struct {
+	name     string
+	min, max int
+	names    []string // names of the values starting at min, if any
+}
+
+var (
+	cronMinute   = cronField{name: "minute", min: 0, max: 59}
+	cronHour     = cronField{name: "hour", min: 0, max: 23}
+	cronDay      = cronField{name: "day of month", min: 1, max: 31}
+	cronMonth    = cronField{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	cronWeekday  = cronField{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+	cronFieldSet = []cronField{cronMinute, cronHour, cronDay, cronMonth, cronWeekday}
+)
+
+// cronBits is the set of values matched by a cron field.
+type cronBits uint64
+
+func (b cronBits) has(value int) bool {
+	return b&(1<<uint(value)) != 0
+}
+
+// CronSchedule is a standard five-field cron expression: minute, hour, day of month, month and day of week.
+// Fields accept `*`, values, ranges, lists and steps, and month and weekday names. As in cron, when both
+// the day of month and the day of week are restricted, a day matching either of them fires.
+type CronSchedule struct {
+	expression string
+	minutes    cronBits
+	hours      cronBits
+	days       cronBits
+	months     cronBits
+	weekdays   cronBits
+	// dayOr is set when both day fields are restricted.
+	dayOr bool
+}
+
+// ParseCron parses a five-field cron expression such as "30 2 * * 1-5".
+func ParseCron(expression string) (*CronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != len(cronFieldSet) {
+		return nil, errors.Wrapf(ErrInvalidCronExpression, "'%s' must have 5 fields: minute hour day-of-month month day-of-week", expression)
+	}
+
+	bits := make([]cronBits, len(fields))
+	for i, field := range fields {
+		b, err := cronFieldSet[i].parse(field)
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidCronExpression, "'%s': %s", expression, err.Error())
+		}
+		bits[i] = b
+	}
+
+	// Sunday is both 0 and 7
+	weekdays := bits[4]
+	if weekdays.has(7) {
+		weekdays |= 1
+	}
+
+	schedule := &CronSchedule{
+		expression: expression,
+		minutes:    bits[0],
+		hours:      bits[1],
+		days:       bits[2],
+		months:     bits[3],
+		weekdays:   weekdays &^ (1 << 7),
+		dayOr:      fields[2] != "*" && fields[4] != "*",
+	}
+
+	if schedule.Next(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)).IsZero() {
+		return nil, errors.Wrapf(ErrInvalidCronExpression, "'%s' never fires", expression)
+	}
+	return schedule, nil
+}
+
+func (s *CronSchedule) String() string {
+	return s.expression
+}
+
+// Next returns the first fire time strictly after the given time, in its location. Fire times are read on
+// the wall clock through wallClock, so one skipped by a forward transition fires at the end of the gap and
+// one repeated by a backward transition fires once. It returns the zero time when the expression does not
+// fire within cronSearchYears.
+func (s *CronSchedule) Next(after time.Time) time.Time {
+	loc := after.Location()
+	year, month, day := after.Date()
+
+	// calendar dates; UTC has no transitions to shift them
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	limit := date.AddDate(cronSearchYears, 0, 0)
+
+	for ; date.Before(limit); date = date.AddDate(0, 0, 1) {
+		if !s.months.has(int(date.Month())) || !s.matchDay(date) {
+			continue
+		}
+		for hour := range 24 {
+			if !s.hours.has(hour) {
+				continue
+			}
+			for minute := range 60 {
+				if !s.minutes.has(minute) {
+					continue
+				}
+				// wall clock times map to instants in order, so the first one after is the earliest
+				if t := wallClock(date.Year(), date.Month(), date.Day(), hour, minute, 0, loc); t.After(after) {
+					return t
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func (s *CronSchedule) matchDay(t time.Time) bool {
+	day := s.days.has(t.Day())
+	weekday := s.weekdays.has(int(t.Weekday()))
+	if s.dayOr {
+		return day || weekday
+	}
+	return day && weekday
+}
+
+// parse parses a comma separated list of `*`, values and ranges, each with an optional step.
+func (f cronField) parse(field string) (cronBits, error) {
+	var bits cronBits
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return 0, errors.Errorf("invalid step '%s' in %s field", stepPart, f.name)
+			}
+		}
+
+		var from, to int
+		switch {
+		case rangePart == "*":
+			from, to = f.min, f.max
+		case strings.Contains(rangePart, "-"):
+			fromPart, toPart, _ := strings.Cut(rangePart, "-")
+			var err error
+			if from, err = f.value(fromPart); err != nil {
+				return 0, err
+			}
+			if to, err = f.value(toPart); err != nil {
+				return 0, err
+			}
+			if from > to {
+				return 0, errors.Errorf("invalid range '%s' in %s field", rangePart, f.name)
+			}
+		default:
+			value, err := f.value(rangePart)
+			if err != nil {
+				return 0, err
+			}
+			from, to = value, value
+			// "a/n" means from a to the maximum every n
+			if hasStep {
+				to = f.max
+			}
+		}
+
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+
+	value, err := strconv.Atoi(s)
+	if err != nil || value < f.min || value > f.max {
+		return 0, errors.Errorf("%s must be between %d and %d, got '%s'", f.name, f.min, f.max, s)
+	}
+	return value, nil
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCron(t *testing.T) {
+	valid := []string{"* * * * *", "30 2 * * 1-5", "*/15 0-6/2 1,15 jan-jun mon,fri", "0 0 * * 7", "5/10 * * * *"}
+	for _, expression := range valid {
+		_, err := ParseCron(expression)
+		assert.NoError(t, err, expression)
+	}
+
+	invalid := []string{"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "x * * * *", "0 0 30 2 *"}
+	for _, expression := range invalid {
+		_, err := ParseCron(expression)
+		assert.ErrorIs(t, err, ErrInvalidCronExpression, expression)
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	// Friday
+	after := time.Date(2024, 3, 1, 10, 15, 30, 0, time.UTC)
+
+	tests := []struct {
+		expression string
+		want       time.Time
+	}{
+		{expression: "* * * * *", want: time.Date(2024, 3, 1, 10, 16, 0, 0, time.UTC)},
+		{expression: "30 2 * * 1-5", want: time.Date(2024, 3, 4, 2, 30, 0, 0, time.UTC)},
+		{expression: "0 12 * * *", want: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+		{expression: "0 0 1 * *", want: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{expression: "0 0 29 feb *", want: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{expression: "0 0 * * sun", want: time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+		{expression: "0 0 * * 7", want: time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+		// both day fields restricted: either matches
+		{expression: "0 0 15 * mon", want: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{expression: "*/20 10 * * *", want: time.Date(2024, 3, 1, 10, 20, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			schedule, err := ParseCron(tt.expression)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, schedule.Next(after))
+		})
+	}
+}
+
+func TestCronScheduleNextTimeZone(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	utc := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		after      time.Time
+		want       time.Time
+	}{
+		{name: "skipped time runs at the end of the gap", expression: "30 2 * * *", after: utc(2024, 3, 30, 11, 0), want: utc(2024, 3, 31, 1, 0)},
+		{name: "day after the skipped time", expression: "30 2 * * *", after: utc(2024, 3, 31, 1, 0), want: utc(2024, 4, 1, 0, 30)},
+		{name: "skipped minutes run once", expression: "*/20 2 * * *", after: utc(2024, 3, 31, 1, 0), want: utc(2024, 4, 1, 0, 0)},
+		{name: "repeated time runs at its first occurrence", expression: "30 2 * * *", after: utc(2024, 10, 26, 12, 0), want: utc(2024, 10, 27, 0, 30)},
+		{name: "repeated time runs once", expression: "30 2 * * *", after: utc(2024, 10, 27, 0, 30), want: utc(2024, 10, 28, 1, 30)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCron(tt.expression)
+			require.NoError(t, err)
+			got := schedule.Next(tt.after.In(berlin))
+			assert.True(t, tt.want.Equal(got), "want %s, got %s", tt.want, got.UTC())
+		})
+	}
+}
+
+func TestGetPostRetentionJobSettingsCron(t *testing.T) {
+	c := NewConfiguration()
+	c.EnableRetentionPolicy = true
+	c.Frequency = string(Cron)
+	c.DayOfWeek = "1"
+	c.TimeOfDay = "1:00am +0000"
+
+	c.CronExpression = "30 2 * * 1-5"
+	settings, err := c.GetPostRetentionJobSettings()
+	require.NoError(t, err)
+	assert.Equal(t, "30 2 * * 1-5", settings.Cron.String())
+
+	c.CronExpression = "30 2 * *"
+	_, err = c.GetPostRetentionJobSettings()
+	assert.ErrorIs(t, err, ErrInvalidCronExpression)
+}
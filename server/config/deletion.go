@@ -0,0 +1,37 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DeletionBackendMmctl     DeletionBackend = "mmctl"     // Permanent deletion through the local mode unix socket
+	DeletionBackendPluginAPI DeletionBackend = "pluginapi" // Soft deletion through the plugin API
+	DeletionBackendHTTP      DeletionBackend = "http"      // Permanent deletion through the REST API
+
+	DefaultDeletionBackend = DeletionBackendMmctl
+)
+
+var (
+	ErrInvalidDeletionBackend = errors.New("invalid deletion backend")
+)
+
+// DeletionBackend selects how the retention job deletes posts.
+type DeletionBackend string
+
+func DeletionBackendFromString(s string) (DeletionBackend, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return DefaultDeletionBackend, nil
+	case string(DeletionBackendMmctl):
+		return DeletionBackendMmctl, nil
+	case string(DeletionBackendPluginAPI):
+		return DeletionBackendPluginAPI, nil
+	case string(DeletionBackendHTTP):
+		return DeletionBackendHTTP, nil
+	default:
+		return "", errors.Wrapf(ErrInvalidDeletionBackend, "'%s' is not a valid deletion backend", s)
+	}
+}
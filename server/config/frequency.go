@@ -8,9 +8,16 @@ import (
 )
 
 const (
-	Monthly Frequency = "monthly" // Run job monthly
-	Daily   Frequency = "daily"   // Run job daily
-	Weekly  Frequency = "weekly"  // Run job weekly
+	Monthly     Frequency = "monthly"       // Run job monthly
+	Daily       Frequency = "daily"         // Run job daily
+	Weekly      Frequency = "weekly"        // Run job weekly
+	Cron        Frequency = "cron"          // Run job on a cron expression
+	Hourly      Frequency = "hourly"        // Run job hourly
+	EveryNHours Frequency = "every_n_hours" // Run job every interval hours
+	EveryNDays  Frequency = "every_n_days"  // Run job every interval days
+
+	MaxIntervalHours = 23
+	MaxIntervalDays  = 365
 )
 
 var (
@@ -27,44 +34,100 @@ func FreqFromString(s string) (Frequency, error) {
 		return Weekly, nil
 	case string(Daily):
 		return Daily, nil
+	case string(Cron):
+		return Cron, nil
+	case string(Hourly):
+		return Hourly, nil
+	case string(EveryNHours):
+		return EveryNHours, nil
+	case string(EveryNDays):
+		return EveryNDays, nil
 	default:
 		return "", errors.Wrapf(ErrInvalidFrequency, "'%s' is not a valid frequency", s)
 	}
 }
 
 // CalcNext determines the next time based on a starting time, this frequency, and the time of day option.
-func (f Frequency) CalcNext(last time.Time, dayOfWeek int, timeOfDay time.Time) time.Time {
-	// everything in UTC
-	originalLocation := timeOfDay.Location()
-	last = last.In(originalLocation)
+// Dates are computed on the wall clock of the time of day location, so a run keeps its local time across
+// daylight saving transitions; see wallClock for the skipped and repeated hours. The interval is the
+// number of hours or days of EveryNHours and EveryNDays.
+func (f Frequency) CalcNext(last time.Time, dayOfWeek int, interval int, timeOfDay time.Time) time.Time {
+	loc := timeOfDay.Location()
+	last = last.In(loc)
+	year, month, day := last.Date()
+
+	switch f {
+	case Hourly:
+		return calcNextHours(last, 1, timeOfDay)
+	case EveryNHours:
+		return calcNextHours(last, interval, timeOfDay)
+	}
 
-	var next time.Time
 	var dowAdjust bool
 
 	switch f {
 	case Monthly:
-		next = time.Date(last.Year(), last.Month()+1, last.Day(), timeOfDay.Hour(), timeOfDay.Minute(), timeOfDay.Second(), 0, timeOfDay.Location())
+		// clamped, so January 31 is followed by the end of February rather than March
+		day = min(day, daysIn(year, month+1))
+		month++
 		dowAdjust = true
 	case Weekly:
-		next = time.Date(last.Year(), last.Month(), last.Day()+7, timeOfDay.Hour(), timeOfDay.Minute(), timeOfDay.Second(), 0, timeOfDay.Location())
+		day += 7
 		dowAdjust = true
 	case Daily:
-		dailyDay := last.Day()
 		if last.Hour() > timeOfDay.Hour() || (last.Hour() == timeOfDay.Hour() && last.Minute() >= timeOfDay.Minute()) {
-			dailyDay += 1
+			day += 1
+		}
+	case EveryNDays:
+		if last.Hour() > timeOfDay.Hour() || (last.Hour() == timeOfDay.Hour() && last.Minute() >= timeOfDay.Minute()) {
+			day += interval
 		}
-		next = time.Date(last.Year(), last.Month(), dailyDay, timeOfDay.Hour(), timeOfDay.Minute(), timeOfDay.Second(), 0, timeOfDay.Location())
 	}
 
+	// normalized calendar date; UTC has no transitions to shift it
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+
 	// adjust for day of week.
 	if dowAdjust {
-		nextWeekday := int(next.Weekday())
+		nextWeekday := int(date.Weekday())
 		if nextWeekday <= dayOfWeek {
-			next = next.AddDate(0, 0, dayOfWeek-nextWeekday)
+			date = date.AddDate(0, 0, dayOfWeek-nextWeekday)
 		} else {
-			next = next.AddDate(0, 0, (dayOfWeek+7)-nextWeekday)
+			date = date.AddDate(0, 0, (dayOfWeek+7)-nextWeekday)
 		}
 	}
 
-	return next.In(originalLocation)
+	next := wallClock(date.Year(), date.Month(), date.Day(), timeOfDay.Hour(), timeOfDay.Minute(), timeOfDay.Second(), loc)
+
+	// a last run finishing in the hour repeated by a backward transition reads before the time of day on
+	// the wall clock, while the run of that day already started at its first occurrence
+	if !next.After(last) && (f == Daily || f == EveryNDays) {
+		days := 1
+		if f == EveryNDays {
+			days = interval
+		}
+		date = date.AddDate(0, 0, days)
+		next = wallClock(date.Year(), date.Month(), date.Day(), timeOfDay.Hour(), timeOfDay.Minute(), timeOfDay.Second(), loc)
+	}
+	return next
+}
+
+// calcNextHours returns the first run after last at the minute of the time of day, in an hour that is
+// a multiple of interval hours away from the time of day on the same day. An interval that does not
+// divide 24 leaves a shorter gap around midnight.
+func calcNextHours(last time.Time, interval int, timeOfDay time.Time) time.Time {
+	loc := timeOfDay.Location()
+	year, month, day := last.Date()
+
+	for ; ; day++ {
+		for hour := range 24 {
+			if ((hour-timeOfDay.Hour())%interval+interval)%interval != 0 {
+				continue
+			}
+			next := wallClock(year, month, day, hour, timeOfDay.Minute(), timeOfDay.Second(), loc)
+			if next.After(last) {
+				return next
+			}
+		}
+	}
 }
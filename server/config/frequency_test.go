@@ -0,0 +1,186 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrequencyCalcNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	utc := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name      string
+		freq      Frequency
+		dayOfWeek int
+		interval  int
+		timeOfDay string
+		loc       *time.Location
+		last      time.Time
+		want      time.Time
+	}{
+		{
+			name: "daily, standard time", freq: Daily, timeOfDay: "1:00am", loc: berlin,
+			last: utc(2024, 3, 30, 0, 5), want: utc(2024, 3, 31, 0, 0),
+		},
+		{
+			name: "daily, skipped hour runs at the end of the gap", freq: Daily, timeOfDay: "2:30am", loc: berlin,
+			last: utc(2024, 3, 30, 1, 35), want: utc(2024, 3, 31, 1, 0),
+		},
+		{
+			name: "daily, after the skipped hour", freq: Daily, timeOfDay: "2:30am", loc: berlin,
+			last: utc(2024, 3, 31, 1, 5), want: utc(2024, 4, 1, 0, 30),
+		},
+		{
+			name: "daily, before spring transition keeps local time", freq: Daily, timeOfDay: "1:00am", loc: berlin,
+			last: utc(2024, 3, 31, 0, 5), want: utc(2024, 3, 31, 23, 0),
+		},
+		{
+			name: "daily, repeated hour runs at the first occurrence", freq: Daily, timeOfDay: "2:30am", loc: berlin,
+			last: utc(2024, 10, 26, 0, 35), want: utc(2024, 10, 27, 0, 30),
+		},
+		{
+			name: "daily, repeated hour runs once", freq: Daily, timeOfDay: "2:30am", loc: berlin,
+			last: utc(2024, 10, 27, 0, 40), want: utc(2024, 10, 28, 1, 30),
+		},
+		{
+			name: "daily, run finishing in the repeated hour", freq: Daily, timeOfDay: "2:30am", loc: berlin,
+			last: utc(2024, 10, 27, 1, 10), want: utc(2024, 10, 28, 1, 30),
+		},
+		{
+			name: "every 2 days, run finishing in the repeated hour", freq: EveryNDays, interval: 2, timeOfDay: "2:30am", loc: berlin,
+			last: utc(2024, 10, 27, 1, 10), want: utc(2024, 10, 29, 1, 30),
+		},
+		{
+			name: "daily, after fall transition keeps local time", freq: Daily, timeOfDay: "1:00am", loc: berlin,
+			last: utc(2024, 10, 26, 23, 5), want: utc(2024, 10, 28, 0, 0),
+		},
+		{
+			name: "weekly across spring transition", freq: Weekly, dayOfWeek: 1, timeOfDay: "1:00am", loc: berlin,
+			last: utc(2024, 3, 25, 0, 5), want: utc(2024, 3, 31, 23, 0),
+		},
+		{
+			name: "monthly", freq: Monthly, dayOfWeek: 1, timeOfDay: "1:00am", loc: berlin,
+			last: utc(2024, 2, 5, 0, 5), want: utc(2024, 3, 11, 0, 0),
+		},
+		{
+			name: "monthly, end of month is clamped", freq: Monthly, dayOfWeek: 4, timeOfDay: "1:00am", loc: berlin,
+			last: utc(2024, 1, 31, 0, 5), want: utc(2024, 2, 29, 0, 0),
+		},
+		{
+			name: "daily, new york skipped hour", freq: Daily, timeOfDay: "2:15am", loc: newYork,
+			last: utc(2024, 3, 9, 7, 20), want: utc(2024, 3, 10, 7, 0),
+		},
+		{
+			name: "hourly, at the minute of the time of day", freq: Hourly, timeOfDay: "1:15am", loc: berlin,
+			last: utc(2024, 5, 10, 9, 20), want: utc(2024, 5, 10, 10, 15),
+		},
+		{
+			name: "hourly, across the skipped hour", freq: Hourly, timeOfDay: "1:30am", loc: berlin,
+			last: utc(2024, 3, 31, 0, 35), want: utc(2024, 3, 31, 1, 0),
+		},
+		{
+			name: "hourly, after the skipped hour", freq: Hourly, timeOfDay: "1:30am", loc: berlin,
+			last: utc(2024, 3, 31, 1, 0), want: utc(2024, 3, 31, 1, 30),
+		},
+		{
+			name: "every 6 hours, anchored to the time of day", freq: EveryNHours, interval: 6, timeOfDay: "1:00am", loc: berlin,
+			last: utc(2024, 5, 10, 6, 0), want: utc(2024, 5, 10, 11, 0),
+		},
+		{
+			name: "every 6 hours, next day", freq: EveryNHours, interval: 6, timeOfDay: "1:00am", loc: berlin,
+			last: utc(2024, 5, 10, 17, 5), want: utc(2024, 5, 10, 23, 0),
+		},
+		{
+			name: "every 5 hours, shorter gap around midnight", freq: EveryNHours, interval: 5, timeOfDay: "1:00am", loc: berlin,
+			last: utc(2024, 5, 10, 19, 5), want: utc(2024, 5, 10, 23, 0),
+		},
+		{
+			name: "every 3 days", freq: EveryNDays, interval: 3, timeOfDay: "1:00am", loc: berlin,
+			last: utc(2024, 5, 10, 23, 5), want: utc(2024, 5, 13, 23, 0),
+		},
+		{
+			name: "every 3 days, before the time of day", freq: EveryNDays, interval: 3, timeOfDay: "1:00am", loc: berlin,
+			last: utc(2024, 5, 10, 22, 0), want: utc(2024, 5, 10, 23, 0),
+		},
+		{
+			name: "daily, fixed offset", freq: Daily, timeOfDay: "1:00am -0700",
+			last: utc(2024, 3, 10, 8, 5), want: utc(2024, 3, 11, 8, 0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeOfDay, err := parseTimeOfDay(tt.timeOfDay, tt.loc)
+			require.NoError(t, err)
+
+			got := tt.freq.CalcNext(tt.last, tt.dayOfWeek, tt.interval, timeOfDay)
+			assert.True(t, tt.want.Equal(got), "want %s, got %s", tt.want, got.UTC())
+			if tt.loc != nil {
+				assert.Equal(t, tt.loc, got.Location())
+			}
+		})
+	}
+}
+
+func TestGetPostRetentionJobSettingsInterval(t *testing.T) {
+	c := NewConfiguration()
+	c.EnableRetentionPolicy = true
+	c.DayOfWeek = "1"
+	c.TimeOfDay = "1:00am +0000"
+
+	c.Frequency = string(EveryNHours)
+	c.FrequencyInterval = 12
+	settings, err := c.GetPostRetentionJobSettings()
+	require.NoError(t, err)
+	assert.Equal(t, 12, settings.Interval)
+
+	c.FrequencyInterval = 24
+	_, err = c.GetPostRetentionJobSettings()
+	assert.Error(t, err)
+
+	c.Frequency = string(EveryNDays)
+	settings, err = c.GetPostRetentionJobSettings()
+	require.NoError(t, err)
+	assert.Equal(t, 24, settings.Interval)
+
+	c.FrequencyInterval = 0
+	_, err = c.GetPostRetentionJobSettings()
+	assert.Error(t, err)
+
+	c.Frequency = string(Hourly)
+	settings, err = c.GetPostRetentionJobSettings()
+	require.NoError(t, err)
+	assert.Zero(t, settings.Interval)
+}
+
+func TestGetPostRetentionJobSettingsTimeZone(t *testing.T) {
+	c := NewConfiguration()
+	c.EnableRetentionPolicy = true
+	c.Frequency = string(Daily)
+	c.DayOfWeek = "1"
+
+	c.TimeZone = "Europe/Berlin"
+	c.TimeOfDay = "1:00am"
+	settings, err := c.GetPostRetentionJobSettings()
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Berlin", settings.TimeOfDay.Location().String())
+	assert.Equal(t, 1, settings.TimeOfDay.Hour())
+
+	c.TimeZone = "Mars/Olympus_Mons"
+	_, err = c.GetPostRetentionJobSettings()
+	assert.Error(t, err)
+
+	c.TimeZone = ""
+	_, err = c.GetPostRetentionJobSettings()
+	assert.Error(t, err, "the offset is required without a time zone")
+}
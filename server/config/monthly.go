@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	MonthlyAfterLastRun MonthlyMode = "after_last_run" // One month after the last run, moved to the day of week
+	MonthlyDayOfMonth   MonthlyMode = "day_of_month"   // A fixed day of the month, clamped to the last day
+	MonthlyNthWeekday   MonthlyMode = "nth_weekday"    // The first to fourth, or the last, day of week of the month
+	MonthlyLastDay      MonthlyMode = "last_day"       // The last day of the month
+
+	DefaultMonthlyMode = MonthlyAfterLastRun
+
+	// LastWeekOfMonth selects the last day of week of the month in MonthlySchedule.Week.
+	LastWeekOfMonth = -1
+)
+
+var (
+	ErrInvalidMonthlyMode = errors.New("invalid monthly mode")
+)
+
+// MonthlyMode selects on which day of the month a monthly schedule runs.
+type MonthlyMode string
+
+func MonthlyModeFromString(s string) (MonthlyMode, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return DefaultMonthlyMode, nil
+	case string(MonthlyAfterLastRun):
+		return MonthlyAfterLastRun, nil
+	case string(MonthlyDayOfMonth):
+		return MonthlyDayOfMonth, nil
+	case string(MonthlyNthWeekday):
+		return MonthlyNthWeekday, nil
+	case string(MonthlyLastDay):
+		return MonthlyLastDay, nil
+	default:
+		return "", errors.Wrapf(ErrInvalidMonthlyMode, "'%s' is not a valid monthly mode", s)
+	}
+}
+
+// MonthlySchedule is the day of the month of a monthly frequency.
+type MonthlySchedule struct {
+	Mode MonthlyMode
+	// DayOfMonth is the day for MonthlyDayOfMonth, 1 to 31.
+	DayOfMonth int
+	// Week is the week for MonthlyNthWeekday, 1 to 4 or LastWeekOfMonth, and DayOfWeek its day.
+	Week      int
+	DayOfWeek int
+}
+
+// ParseWeekOfMonth parses the week of month setting: 1 to 4 or "last".
+func ParseWeekOfMonth(s string) (int, error) {
+	if strings.EqualFold(s, "last") {
+		return LastWeekOfMonth, nil
+	}
+	week, err := ParseInt(s, 1, 4)
+	if err != nil {
+		return 0, fmt.Errorf("week of month must be 1 to 4 or last: %w", err)
+	}
+	return week, nil
+}
+
+// CalcNext returns the first run of the schedule after last, at timeOfDay. It does not apply to
+// MonthlyAfterLastRun, which Frequency.CalcNext computes.
+func (m MonthlySchedule) CalcNext(last time.Time, timeOfDay time.Time) time.Time {
+	loc := timeOfDay.Location()
+	last = last.In(loc)
+
+	year, month, _ := last.Date()
+	for {
+		next := wallClock(year, month, m.day(year, month), timeOfDay.Hour(), timeOfDay.Minute(), timeOfDay.Second(), loc)
+		if next.After(last) {
+			return next
+		}
+		month++
+	}
+}
+
+// day returns the day the schedule runs on in the month.
+func (m MonthlySchedule) day(year int, month time.Month) int {
+	lastDay := daysIn(year, month)
+
+	switch m.Mode {
+	case MonthlyDayOfMonth:
+		return min(m.DayOfMonth, lastDay)
+	case MonthlyNthWeekday:
+		if m.Week == LastWeekOfMonth {
+			lastWeekday := int(time.Date(year, month, lastDay, 0, 0, 0, 0, time.UTC).Weekday())
+			return lastDay - (lastWeekday-m.DayOfWeek+7)%7
+		}
+		firstWeekday := int(time.Date(year, month, 1, 0, 0, 0, 0, time.UTC).Weekday())
+		return 1 + (m.DayOfWeek-firstWeekday+7)%7 + (m.Week-1)*7
+	default:
+		return lastDay
+	}
+}
+
+// daysIn returns the number of days of the month.
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonthlyScheduleCalcNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	utc := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		schedule MonthlySchedule
+		last     time.Time
+		want     time.Time
+	}{
+		{
+			name:     "day of month, later this month",
+			schedule: MonthlySchedule{Mode: MonthlyDayOfMonth, DayOfMonth: 15},
+			last:     utc(2024, 1, 10, 12, 0), want: utc(2024, 1, 15, 0, 0),
+		},
+		{
+			name:     "day of month, clamped to february",
+			schedule: MonthlySchedule{Mode: MonthlyDayOfMonth, DayOfMonth: 31},
+			last:     utc(2024, 1, 31, 0, 5), want: utc(2024, 2, 29, 0, 0),
+		},
+		{
+			name:     "day of month, back to the 31st after clamping",
+			schedule: MonthlySchedule{Mode: MonthlyDayOfMonth, DayOfMonth: 31},
+			last:     utc(2024, 2, 29, 0, 5), want: utc(2024, 3, 31, 0, 0),
+		},
+		{
+			name:     "day of month, across the year",
+			schedule: MonthlySchedule{Mode: MonthlyDayOfMonth, DayOfMonth: 1},
+			last:     utc(2024, 12, 5, 0, 0), want: utc(2025, 1, 1, 0, 0),
+		},
+		{
+			name:     "second tuesday",
+			schedule: MonthlySchedule{Mode: MonthlyNthWeekday, Week: 2, DayOfWeek: 2},
+			last:     utc(2024, 5, 1, 0, 0), want: utc(2024, 5, 13, 23, 0),
+		},
+		{
+			name:     "second tuesday, next month",
+			schedule: MonthlySchedule{Mode: MonthlyNthWeekday, Week: 2, DayOfWeek: 2},
+			last:     utc(2024, 5, 13, 23, 5), want: utc(2024, 6, 10, 23, 0),
+		},
+		{
+			name:     "first monday on the first",
+			schedule: MonthlySchedule{Mode: MonthlyNthWeekday, Week: 1, DayOfWeek: 1},
+			last:     utc(2024, 6, 15, 0, 0), want: utc(2024, 6, 30, 23, 0),
+		},
+		{
+			name:     "last friday",
+			schedule: MonthlySchedule{Mode: MonthlyNthWeekday, Week: LastWeekOfMonth, DayOfWeek: 5},
+			last:     utc(2024, 2, 1, 0, 0), want: utc(2024, 2, 23, 0, 0),
+		},
+		{
+			name:     "last sunday on the last day",
+			schedule: MonthlySchedule{Mode: MonthlyNthWeekday, Week: LastWeekOfMonth, DayOfWeek: 0},
+			last:     utc(2024, 3, 1, 0, 0), want: utc(2024, 3, 31, 0, 0),
+		},
+		{
+			name:     "last day, leap year",
+			schedule: MonthlySchedule{Mode: MonthlyLastDay},
+			last:     utc(2024, 2, 10, 0, 0), want: utc(2024, 2, 29, 0, 0),
+		},
+		{
+			name:     "last day, next month",
+			schedule: MonthlySchedule{Mode: MonthlyLastDay},
+			last:     utc(2023, 2, 28, 0, 5), want: utc(2023, 3, 30, 23, 0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeOfDay, err := parseTimeOfDay("1:00am", berlin)
+			require.NoError(t, err)
+
+			got := tt.schedule.CalcNext(tt.last, timeOfDay)
+			assert.True(t, tt.want.Equal(got), "want %s, got %s", tt.want, got.UTC())
+		})
+	}
+}
+
+func TestGetPostRetentionJobSettingsMonthly(t *testing.T) {
+	c := NewConfiguration()
+	c.EnableRetentionPolicy = true
+	c.Frequency = string(Monthly)
+	c.DayOfWeek = "2"
+	c.TimeOfDay = "1:00am +0000"
+
+	c.MonthlyMode = string(MonthlyNthWeekday)
+	c.WeekOfMonth = "last"
+	settings, err := c.GetPostRetentionJobSettings()
+	require.NoError(t, err)
+	assert.Equal(t, MonthlySchedule{Mode: MonthlyNthWeekday, Week: LastWeekOfMonth, DayOfWeek: 2}, settings.Monthly)
+
+	c.WeekOfMonth = "5"
+	_, err = c.GetPostRetentionJobSettings()
+	assert.Error(t, err)
+
+	c.MonthlyMode = string(MonthlyDayOfMonth)
+	c.DayOfMonth = 0
+	_, err = c.GetPostRetentionJobSettings()
+	assert.Error(t, err)
+
+	c.DayOfMonth = 31
+	settings, err = c.GetPostRetentionJobSettings()
+	require.NoError(t, err)
+	assert.Equal(t, 31, settings.Monthly.DayOfMonth)
+
+	c.MonthlyMode = "fortnightly"
+	_, err = c.GetPostRetentionJobSettings()
+	assert.ErrorIs(t, err, ErrInvalidMonthlyMode)
+}
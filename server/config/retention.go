@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"time"
 )
@@ -15,16 +16,37 @@ type RetentionJobSettings struct {
 	EnableRetentionPolicy bool
 	Frequency             Frequency
 	DayOfWeek             int
-	TimeOfDay             time.Time
-	BatchSize             int
+	// Interval is the number of hours or days between runs when Frequency is EveryNHours or EveryNDays.
+	Interval int
+	// TimeOfDay is the wall clock time of the runs; its location is the configured time zone, or the
+	// fixed offset given with the time when none is configured.
+	TimeOfDay time.Time
+	BatchSize int
+	// Cron is the parsed cron expression when Frequency is Cron.
+	Cron *CronSchedule
+	// Monthly is the day of the month when Frequency is Monthly.
+	Monthly MonthlySchedule
+	// WindowEnd is the time of day a run is stopped at; zero when runs have no end time.
+	WindowEnd time.Time
+	// MaxRunDuration is the longest a run may take; zero when unlimited.
+	MaxRunDuration time.Duration
+	// Blackouts are the periods in which the job does not run.
+	Blackouts []BlackoutPeriod
 }
 
 func (c *RetentionJobSettings) Clone() *RetentionJobSettings {
 	return &RetentionJobSettings{
 		EnableRetentionPolicy: c.EnableRetentionPolicy,
 		Frequency:             c.Frequency,
+		DayOfWeek:             c.DayOfWeek,
+		Interval:              c.Interval,
 		TimeOfDay:             c.TimeOfDay,
 		BatchSize:             c.BatchSize,
+		Cron:                  c.Cron,
+		Monthly:               c.Monthly,
+		WindowEnd:             c.WindowEnd,
+		MaxRunDuration:        c.MaxRunDuration,
+		Blackouts:             slices.Clone(c.Blackouts),
 	}
 }
 
@@ -45,16 +67,89 @@ func (c *Configuration) GetPostRetentionJobSettings() (*RetentionJobSettings, er
 		return nil, err
 	}
 
+	var cron *CronSchedule
+	if freq == Cron {
+		cron, err = ParseCron(c.CronExpression)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse `Cron expression`: %w", err)
+		}
+	}
+
+	var interval int
+	switch freq {
+	case EveryNHours:
+		if c.FrequencyInterval < 1 || c.FrequencyInterval > MaxIntervalHours {
+			return nil, fmt.Errorf("`Interval` must be between 1 and %d hours", MaxIntervalHours)
+		}
+		interval = c.FrequencyInterval
+	case EveryNDays:
+		if c.FrequencyInterval < 1 || c.FrequencyInterval > MaxIntervalDays {
+			return nil, fmt.Errorf("`Interval` must be between 1 and %d days", MaxIntervalDays)
+		}
+		interval = c.FrequencyInterval
+	}
+
 	dow, err := ParseInt(c.DayOfWeek, 0, 6)
 	if err != nil {
 		return nil, fmt.Errorf("cannot parse `Day of week`: %w", err)
 	}
 
-	tod, err := time.Parse(TimeOfDayLayout, c.TimeOfDay)
+	monthly := MonthlySchedule{DayOfWeek: dow}
+	if freq == Monthly {
+		if monthly.Mode, err = MonthlyModeFromString(c.MonthlyMode); err != nil {
+			return nil, err
+		}
+		switch monthly.Mode {
+		case MonthlyDayOfMonth:
+			if c.DayOfMonth < 1 || c.DayOfMonth > 31 {
+				return nil, fmt.Errorf("`Day of month` must be between 1 and 31")
+			}
+			monthly.DayOfMonth = c.DayOfMonth
+		case MonthlyNthWeekday:
+			if monthly.Week, err = ParseWeekOfMonth(c.WeekOfMonth); err != nil {
+				return nil, fmt.Errorf("cannot parse `Week of month`: %w", err)
+			}
+		}
+	}
+
+	var loc *time.Location
+	if c.TimeZone != "" {
+		loc, err = time.LoadLocation(c.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load `Time zone`: %w", err)
+		}
+	}
+
+	tod, err := parseTimeOfDay(c.TimeOfDay, loc)
 	if err != nil {
 		return nil, fmt.Errorf("cannot parse `Time of day`: %w", err)
 	}
 
+	var windowEnd time.Time
+	if c.WindowEndTime != "" {
+		windowEnd, err = parseTimeOfDay(c.WindowEndTime, loc)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse `Window end time`: %w", err)
+		}
+	}
+
+	blackouts, err := ParseBlackoutDates(c.BlackoutDates, tod.Location())
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse `Blackout dates`: %w", err)
+	}
+
+	if c.MaxRunDurationMinutes < 0 {
+		return nil, fmt.Errorf("`Maximum run duration` must be greater than or equal to 0")
+	}
+
+	if _, err := DeletionBackendFromString(c.DeletionBackend); err != nil {
+		return nil, err
+	}
+
+	if _, err := c.ParsePostAgeBounds(); err != nil {
+		return nil, err
+	}
+
 	batchSize := c.BatchSize
 	if batchSize < MinBatchSize {
 		batchSize = MinBatchSize
@@ -67,11 +162,30 @@ func (c *Configuration) GetPostRetentionJobSettings() (*RetentionJobSettings, er
 		EnableRetentionPolicy: c.EnableRetentionPolicy,
 		Frequency:             freq,
 		DayOfWeek:             dow,
+		Interval:              interval,
 		TimeOfDay:             tod,
 		BatchSize:             batchSize,
+		Cron:                  cron,
+		Monthly:               monthly,
+		WindowEnd:             windowEnd,
+		MaxRunDuration:        time.Duration(c.MaxRunDurationMinutes) * time.Minute,
+		Blackouts:             blackouts,
 	}, nil
 }
 
+// NextRun returns the next scheduled run after the last one finished. Cron expressions are evaluated in
+// the time zone of the time of day.
+func (c *RetentionJobSettings) NextRun(last time.Time) time.Time {
+	switch {
+	case c.Frequency == Cron:
+		return c.Cron.Next(last.In(c.TimeOfDay.Location()))
+	case c.Frequency == Monthly && c.Monthly.Mode != MonthlyAfterLastRun:
+		return c.Monthly.CalcNext(last, c.TimeOfDay)
+	default:
+		return c.Frequency.CalcNext(last, c.DayOfWeek, c.Interval, c.TimeOfDay)
+	}
+}
+
 func ParseInt(s string, minVal int, maxVal int) (int, error) {
 	i64, err := strconv.ParseInt(s, 10, 32)
 	if err != nil {
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	// embed the IANA time zone database, as plugin hosts may not ship one
+	_ "time/tzdata"
+)
+
+// TimeOfDayWallLayout is the layout of a time of day without offset, used when a time zone is configured.
+const TimeOfDayWallLayout = "3:04pm"
+
+// zoneProbe is how far from a wall clock time the zone offsets around it are looked up; no zone changes
+// its offset twice within it.
+const zoneProbe = 26 * time.Hour
+
+// parseTimeOfDay parses a time of day setting. Without a location the offset is required and fixes the
+// time zone; with a location the offset is optional and ignored, the time being a wall clock time in it.
+func parseTimeOfDay(value string, loc *time.Location) (time.Time, error) {
+	tod, err := time.Parse(TimeOfDayLayout, value)
+	if loc == nil {
+		return tod, err
+	}
+	if err != nil {
+		if tod, err = time.Parse(TimeOfDayWallLayout, value); err != nil {
+			return time.Time{}, fmt.Errorf("expected '%s' or '%s': %w", TimeOfDayWallLayout, TimeOfDayLayout, err)
+		}
+	}
+	return time.Date(2000, 1, 1, tod.Hour(), tod.Minute(), 0, 0, loc), nil
+}
+
+// wallClock returns the first instant the wall clock of loc shows the given date and time. A time
+// skipped by a forward transition resolves to the end of the gap; a time repeated by a backward
+// transition resolves to its first occurrence.
+func wallClock(year int, month time.Month, day, hour, minute, sec int, loc *time.Location) time.Time {
+	naive := time.Date(year, month, day, hour, minute, sec, 0, time.UTC)
+
+	var first time.Time
+	for _, probe := range []time.Duration{-zoneProbe, zoneProbe} {
+		_, offset := naive.Add(probe).In(loc).Zone()
+		t := naive.Add(-time.Duration(offset) * time.Second).In(loc)
+		if _, actual := t.Zone(); actual == offset && (first.IsZero() || t.Before(first)) {
+			first = t
+		}
+	}
+	if !first.IsZero() {
+		return first
+	}
+
+	// skipped: read with the offset before the transition, the time falls in the new zone period,
+	// which starts at the end of the gap
+	_, offset := naive.Add(-zoneProbe).In(loc).Zone()
+	start, _ := naive.Add(-time.Duration(offset) * time.Second).In(loc).ZoneBounds()
+	return start
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"time"
+)
+
+// RunDeadline returns when a run started at start must stop: at the first window end after start or
+// after the maximum run duration, whichever comes first. The zero time means the run is not limited.
+func (c *RetentionJobSettings) RunDeadline(start time.Time) time.Time {
+	var deadline time.Time
+	if c.MaxRunDuration > 0 {
+		deadline = start.Add(c.MaxRunDuration)
+	}
+	if !c.WindowEnd.IsZero() {
+		windowEnd := Daily.CalcNext(start, 0, 0, c.WindowEnd)
+		if deadline.IsZero() || windowEnd.Before(deadline) {
+			deadline = windowEnd
+		}
+	}
+	return deadline
+}
+
+// NextWindowStart returns when the next window opens if t is outside of the window, from TimeOfDay up to
+// WindowEnd excluded, or the zero time when t is within it or runs have no end time.
+func (c *RetentionJobSettings) NextWindowStart(t time.Time) time.Time {
+	if c.WindowEnd.IsZero() {
+		return time.Time{}
+	}
+
+	// within the window, it closes before the next one opens
+	start := Daily.CalcNext(t, 0, 0, c.TimeOfDay)
+	if !start.Before(Daily.CalcNext(t, 0, 0, c.WindowEnd)) {
+		return time.Time{}
+	}
+	return start
+}
+
+// RunnableAt returns the first time at or after t a run may execute at: outside of the frozen periods and
+// within the window.
+func (c *RetentionJobSettings) RunnableAt(t time.Time, freezeUntil time.Time) time.Time {
+	for {
+		if until := c.FrozenUntil(t, freezeUntil); !until.IsZero() {
+			t = until
+			continue
+		}
+		if start := c.NextWindowStart(t); !start.IsZero() {
+			t = start
+			continue
+		}
+		return t
+	}
+}
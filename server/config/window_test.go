@@ -0,0 +1,113 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunDeadline(t *testing.T) {
+	windowEnd, err := time.Parse(TimeOfDayLayout, "5:00am +0000")
+	assert.NoError(t, err)
+	start := time.Date(2024, 3, 10, 1, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		settings RetentionJobSettings
+		start    time.Time
+		want     time.Time
+	}{
+		{name: "unlimited", settings: RetentionJobSettings{}, start: start, want: time.Time{}},
+		{name: "max duration", settings: RetentionJobSettings{MaxRunDuration: time.Hour}, start: start, want: start.Add(time.Hour)},
+		{name: "window end", settings: RetentionJobSettings{WindowEnd: windowEnd}, start: start, want: time.Date(2024, 3, 10, 5, 0, 0, 0, time.UTC)},
+		{name: "window end on the next day", settings: RetentionJobSettings{WindowEnd: windowEnd}, start: start.Add(6 * time.Hour), want: time.Date(2024, 3, 11, 5, 0, 0, 0, time.UTC)},
+		{name: "earliest of both", settings: RetentionJobSettings{WindowEnd: windowEnd, MaxRunDuration: 2 * time.Hour}, start: start, want: start.Add(2 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.settings.RunDeadline(tt.start)
+			assert.True(t, tt.want.Equal(got), "got %s", got)
+		})
+	}
+}
+
+func TestNextWindowStart(t *testing.T) {
+	parse := func(s string) time.Time {
+		tod, err := time.Parse(TimeOfDayLayout, s)
+		assert.NoError(t, err)
+		return tod
+	}
+	day := func(hour, minute int) time.Time {
+		return time.Date(2024, 3, 10, hour, minute, 0, 0, time.UTC)
+	}
+
+	night := RetentionJobSettings{TimeOfDay: parse("1:00am +0000"), WindowEnd: parse("5:00am +0000")}
+	overMidnight := RetentionJobSettings{TimeOfDay: parse("10:00pm +0000"), WindowEnd: parse("4:00am +0000")}
+
+	tests := []struct {
+		name     string
+		settings RetentionJobSettings
+		t        time.Time
+		want     time.Time
+	}{
+		{name: "no window end", settings: RetentionJobSettings{TimeOfDay: parse("1:00am +0000")}, t: day(12, 0), want: time.Time{}},
+		{name: "window start", settings: night, t: day(1, 0), want: time.Time{}},
+		{name: "within the window", settings: night, t: day(3, 30), want: time.Time{}},
+		{name: "before the window", settings: night, t: day(0, 30), want: day(1, 0)},
+		{name: "window end", settings: night, t: day(5, 0), want: time.Date(2024, 3, 11, 1, 0, 0, 0, time.UTC)},
+		{name: "midday", settings: night, t: day(12, 0), want: time.Date(2024, 3, 11, 1, 0, 0, 0, time.UTC)},
+		{name: "over midnight before it", settings: overMidnight, t: day(23, 0), want: time.Time{}},
+		{name: "over midnight after it", settings: overMidnight, t: day(2, 0), want: time.Time{}},
+		{name: "over midnight outside", settings: overMidnight, t: day(12, 0), want: day(22, 0)},
+		{name: "whole day", settings: RetentionJobSettings{TimeOfDay: parse("1:00am +0000"), WindowEnd: parse("1:00am +0000")}, t: day(12, 0), want: time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.settings.NextWindowStart(tt.t)
+			assert.True(t, tt.want.Equal(got), "got %s", got)
+		})
+	}
+}
+
+func TestRunnableAt(t *testing.T) {
+	start, err := time.Parse(TimeOfDayLayout, "1:00am +0000")
+	assert.NoError(t, err)
+	windowEnd, err := time.Parse(TimeOfDayLayout, "5:00am +0000")
+	assert.NoError(t, err)
+	blackouts, err := ParseBlackoutDates("2024-03-11", time.UTC)
+	assert.NoError(t, err)
+	settings := RetentionJobSettings{TimeOfDay: start, WindowEnd: windowEnd, Blackouts: blackouts}
+
+	tests := []struct {
+		name        string
+		t           time.Time
+		freezeUntil time.Time
+		want        time.Time
+	}{
+		{name: "runnable", t: time.Date(2024, 3, 10, 2, 0, 0, 0, time.UTC), want: time.Date(2024, 3, 10, 2, 0, 0, 0, time.UTC)},
+		{name: "next window", t: time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC), want: time.Date(2024, 3, 10, 1, 0, 0, 0, time.UTC)},
+		{name: "window in blackout dates", t: time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC), want: time.Date(2024, 3, 12, 1, 0, 0, 0, time.UTC)},
+		{
+			name:        "freeze ending within the window",
+			t:           time.Date(2024, 3, 10, 2, 0, 0, 0, time.UTC),
+			freezeUntil: time.Date(2024, 3, 10, 3, 0, 0, 0, time.UTC),
+			want:        time.Date(2024, 3, 10, 3, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "freeze ending after the window",
+			t:           time.Date(2024, 3, 10, 2, 0, 0, 0, time.UTC),
+			freezeUntil: time.Date(2024, 3, 10, 6, 0, 0, 0, time.UTC),
+			want:        time.Date(2024, 3, 12, 1, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := settings.RunnableAt(tt.t, tt.freezeUntil)
+			assert.True(t, tt.want.Equal(got), "got %s", got)
+		})
+	}
+}
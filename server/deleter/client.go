@@ -0,0 +1,51 @@
+package deleter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/mmctl/client"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/mmctl/commands"
+)
+
+// ClientDeleter permanently deletes posts, with their files, through the REST API of the server.
+type ClientDeleter struct {
+	client client.Client
+}
+
+// NewMmctlDeleter connects to the server through the local mode unix socket, like `mmctl --local` does.
+// Local mode must be enabled in the server configuration.
+func NewMmctlDeleter(socketPath string) (*ClientDeleter, error) {
+	c, err := commands.InitUnixClient(socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to the local mode socket: %w", err)
+	}
+	return &ClientDeleter{client: c}, nil
+}
+
+// NewHTTPDeleter connects to the server REST API at siteURL, authenticating with a personal access
+// or bot token. The token owner must be allowed to delete any post and ServiceSettings.EnableAPIPostDeletion
+// must be enabled for permanent deletion.
+func NewHTTPDeleter(siteURL string, token string) (*ClientDeleter, error) {
+	if siteURL == "" {
+		return nil, fmt.Errorf("the server URL is not set")
+	}
+	if token == "" {
+		return nil, fmt.Errorf("the API token is not set")
+	}
+
+	c := commands.NewAPIv4Client(siteURL, false, false)
+	c.AuthType = model.HeaderBearer
+	c.AuthToken = token
+
+	return &ClientDeleter{client: c}, nil
+}
+
+func (d *ClientDeleter) DeletePosts(ctx context.Context, postIDs []string) []Result {
+	return deleteEach(ctx, postIDs, func(ctx context.Context, postID string) error {
+		_, err := d.client.PermanentDeletePost(ctx, postID)
+		return err
+	})
+}
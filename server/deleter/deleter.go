@@ -0,0 +1,32 @@
+package deleter
+
+import (
+	"context"
+)
+
+// Result is the outcome of deleting a single post.
+type Result struct {
+	PostID string
+	// Err is nil when the post was deleted.
+	Err error
+}
+
+// PostDeleter deletes posts on behalf of the retention job.
+type PostDeleter interface {
+	// DeletePosts deletes the posts one by one and returns a result for every post, in order.
+	// Posts not attempted because the context was cancelled are reported with the context error.
+	DeletePosts(ctx context.Context, postIDs []string) []Result
+}
+
+// deleteEach calls deleteFn for every post until the context is cancelled.
+func deleteEach(ctx context.Context, postIDs []string, deleteFn func(ctx context.Context, postID string) error) []Result {
+	results := make([]Result, 0, len(postIDs))
+	for _, postID := range postIDs {
+		if err := ctx.Err(); err != nil {
+			results = append(results, Result{PostID: postID, Err: err})
+			continue
+		}
+		results = append(results, Result{PostID: postID, Err: deleteFn(ctx, postID)})
+	}
+	return results
+}
@@ -0,0 +1,23 @@
+package deleter
+
+import (
+	"context"
+
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+// PluginAPIDeleter deletes posts in-process through the plugin API. The plugin API has no permanent
+// deletion, so posts are marked as deleted and removed from clients but stay in the database.
+type PluginAPIDeleter struct {
+	client *pluginapi.Client
+}
+
+func NewPluginAPIDeleter(client *pluginapi.Client) *PluginAPIDeleter {
+	return &PluginAPIDeleter{client: client}
+}
+
+func (d *PluginAPIDeleter) DeletePosts(ctx context.Context, postIDs []string) []Result {
+	return deleteEach(ctx, postIDs, func(_ context.Context, postID string) error {
+		return d.client.Post.DeletePost(postID)
+	})
+}
@@ -0,0 +1,69 @@
+package deleter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimited limits the rate at which the wrapped PostDeleter deletes posts. The limit is shared by all
+// concurrent callers.
+type RateLimited struct {
+	inner    PostDeleter
+	interval time.Duration
+
+	mux  sync.Mutex
+	next time.Time
+}
+
+// NewRateLimited wraps inner so that it deletes at most postsPerSecond posts per second. Zero or less
+// returns inner unchanged.
+func NewRateLimited(inner PostDeleter, postsPerSecond int) PostDeleter {
+	if postsPerSecond <= 0 {
+		return inner
+	}
+	return &RateLimited{
+		inner:    inner,
+		interval: time.Second / time.Duration(postsPerSecond),
+	}
+}
+
+func (d *RateLimited) DeletePosts(ctx context.Context, postIDs []string) []Result {
+	return deleteEach(ctx, postIDs, func(ctx context.Context, postID string) error {
+		if err := d.wait(ctx); err != nil {
+			return err
+		}
+		return d.inner.DeletePosts(ctx, []string{postID})[0].Err
+	})
+}
+
+// wait blocks until the next deletion slot or the context is cancelled.
+func (d *RateLimited) wait(ctx context.Context) error {
+	delay := time.Until(d.reserve())
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reserve returns the time of the next free slot and books it.
+func (d *RateLimited) reserve() time.Time {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	now := time.Now()
+	if d.next.Before(now) {
+		d.next = now
+	}
+	slot := d.next
+	d.next = d.next.Add(d.interval)
+	return slot
+}
@@ -0,0 +1,55 @@
+package deleter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingDeleter struct {
+	deleted []string
+}
+
+func (d *recordingDeleter) DeletePosts(_ context.Context, postIDs []string) []Result {
+	results := make([]Result, 0, len(postIDs))
+	for _, postID := range postIDs {
+		d.deleted = append(d.deleted, postID)
+		results = append(results, Result{PostID: postID})
+	}
+	return results
+}
+
+func TestRateLimited(t *testing.T) {
+	t.Run("no limit returns the inner deleter", func(t *testing.T) {
+		inner := &recordingDeleter{}
+		assert.Same(t, inner, NewRateLimited(inner, 0))
+	})
+
+	t.Run("spaces deletions", func(t *testing.T) {
+		inner := &recordingDeleter{}
+		limited := NewRateLimited(inner, 100)
+
+		start := time.Now()
+		results := limited.DeletePosts(context.Background(), []string{"a", "b", "c", "d", "e"})
+
+		assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+		assert.Equal(t, []string{"a", "b", "c", "d", "e"}, inner.deleted)
+		assert.Len(t, results, 5)
+	})
+
+	t.Run("cancelled context skips remaining posts", func(t *testing.T) {
+		inner := &recordingDeleter{}
+		limited := NewRateLimited(inner, 1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		results := limited.DeletePosts(ctx, []string{"a", "b", "c"})
+
+		assert.Equal(t, []string{"a"}, inner.deleted)
+		assert.NoError(t, results[0].Err)
+		assert.ErrorIs(t, results[1].Err, context.DeadlineExceeded)
+		assert.ErrorIs(t, results[2].Err, context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/config"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store/kvstore"
+)
+
+// freezeRetryInterval is how long the job waits before checking again when the freeze cannot be read.
+const freezeRetryInterval = time.Minute
+
+var errFrozen = errors.New("the retention job is frozen")
+
+// Freeze pauses the retention job until the time parsed from until, a duration or a date in the time
+// zone of the schedule, and cancels the run in progress. It returns when the freeze ends, also with the
+// error of a failed cancellation.
+func (p *Plugin) Freeze(until string, reason string, userID string) (time.Time, error) {
+	loc := time.UTC
+	if settings, err := p.getConfiguration().GetPostRetentionJobSettings(); err == nil && !settings.TimeOfDay.IsZero() {
+		loc = settings.TimeOfDay.Location()
+	}
+
+	now := time.Now()
+	end, err := config.ParseFreezeUntil(until, now, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	freeze := &kvstore.Freeze{
+		Until:  model.GetMillisForTime(end),
+		SetBy:  userID,
+		SetAt:  model.GetMillisForTime(now),
+		Reason: reason,
+	}
+	if err := p.kvStore.SetFreeze(freeze); err != nil {
+		return time.Time{}, err
+	}
+	p.API.LogInfo("Posts Retention frozen", "until", end.Format(config.FullLayout), "userId", userID, "reason", reason)
+
+	if _, err := p.backgroundJobHelper.CancelRun(); err != nil {
+		return end, fmt.Errorf("cannot cancel the run in progress: %w", err)
+	}
+	return end, nil
+}
+
+// Unfreeze lifts the freeze set by Freeze. Blackout dates still apply.
+func (p *Plugin) Unfreeze(userID string) error {
+	if err := p.kvStore.DeleteFreeze(); err != nil {
+		return err
+	}
+	p.API.LogInfo("Posts Retention unfrozen", "userId", userID)
+
+	// the schedule waits for the end of the freeze otherwise
+	p.backgroundJobHelper.mux.Lock()
+	running := p.backgroundJobHelper.runner != nil
+	p.backgroundJobHelper.mux.Unlock()
+	if !running {
+		return p.backgroundJobHelper.OnConfigurationChange()
+	}
+	return nil
+}
+
+// freezeUntil returns when the freeze set by an admin ends, or the zero time when none is set.
+func (p *Plugin) freezeUntil() (time.Time, error) {
+	freeze, err := p.kvStore.GetFreeze()
+	if err != nil || freeze == nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(freeze.Until), nil
+}
+
+// checkFrozen returns when the frozen period the job is in at now ends, and why it is frozen. The time is
+// zero when the job is not frozen. A freeze that cannot be read is taken as active, since deleting posts
+// during a freeze cannot be undone.
+func (p *Plugin) checkFrozen(settings *config.RetentionJobSettings, now time.Time) (time.Time, string) {
+	freeze, err := p.kvStore.GetFreeze()
+	if err != nil {
+		p.API.LogError("Cannot fetch Posts Retention freeze", "err", err)
+		return now.Add(freezeRetryInterval), "the freeze cannot be read: " + err.Error()
+	}
+
+	var freezeUntil time.Time
+	if freeze != nil {
+		freezeUntil = time.UnixMilli(freeze.Until)
+	}
+
+	until := settings.FrozenUntil(now, freezeUntil)
+	switch {
+	case until.IsZero():
+		return until, ""
+	case freeze.Active(model.GetMillisForTime(now)) && freeze.Reason != "":
+		return until, "frozen by an admin: " + freeze.Reason
+	case freeze.Active(model.GetMillisForTime(now)):
+		return until, "frozen by an admin"
+	default:
+		return until, "blackout dates"
+	}
+}
@@ -2,21 +2,77 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"sync"
 	"time"
 
 	"github.com/chaos-synthesis/mattermost-plugin-retention/server/config"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/deleter"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store/kvstore"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/throttle"
+	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
 	"github.com/wiggin77/merror"
 )
 
+// Trigger is what started a retention run.
+type Trigger string
+
+const (
+	TriggerScheduled Trigger = "scheduled"
+	TriggerManual    Trigger = "manual"
+)
+
+const (
+	retentionJobKey = "posts_retention_policy_background_job"
+	// retentionJobClusterKey is the key cluster.Schedule uses for the job mutex and metadata.
+	retentionJobClusterKey = "cron_" + retentionJobKey
+)
+
+const (
+	// minResumeRetryInterval and maxResumeRetryInterval bound the wait before resuming an interrupted run
+	// that failed to start.
+	minResumeRetryInterval = time.Minute
+	maxResumeRetryInterval = time.Hour
+)
+
+var (
+	// errWindowClosed cancels a run reaching the end of the maintenance window.
+	errWindowClosed = errors.New("window closed")
+	// errRunCancelled cancels a run on an admin's request.
+	errRunCancelled = errors.New("cancelled by an admin")
+)
+
+// runJob is the callback of the scheduled job. It does not start a run while the job is frozen or outside
+// of the window; the run left over is then continued at the next scheduled run.
 func (p *Plugin) runJob() {
-	// Include job logic here
-	p.API.LogInfo("Retention Job is currently running")
+	cfg := p.getConfiguration()
+	if settings, err := cfg.GetPostRetentionJobSettings(); err == nil {
+		now := time.Now()
+		if until, why := p.checkFrozen(settings, now); !until.IsZero() {
+			p.API.LogInfo("Posts Retention run not started, the job is frozen", "until", until.Format(config.FullLayout), "reason", why)
+			return
+		}
+		if start := settings.NextWindowStart(now); !start.IsZero() {
+			p.API.LogInfo("Posts Retention run not started outside of the window", "opens", start.Format(config.FullLayout))
+			if !cfg.DryRun {
+				p.deferCheckpoint(nil)
+			}
+			return
+		}
+	}
+
+	p.runRetention(TriggerScheduled, cfg.DryRun)
+}
+
+// runRetention runs the retention policies once. The caller must hold the job mutex.
+func (p *Plugin) runRetention(trigger Trigger, dryRun bool) {
+	p.API.LogInfo("Retention Job is currently running", "trigger", trigger)
 
 	exitSignal := make(chan struct{})
-	ctx, canceller := context.WithCancel(context.Background())
+	ctx, canceller := context.WithCancelCause(context.Background())
 
 	runner := &runInstance{
 		canceller:  canceller,
@@ -41,17 +97,232 @@ func (p *Plugin) runJob() {
 		return
 	}
 
+	cfg := p.getConfiguration()
 	opts := ArchiverOpts{
-		BatchSize: p.getConfiguration().BatchSize,
+		RunID:             model.NewId(),
+		BatchSize:         cfg.BatchSize,
+		Workers:           cfg.GetWorkers(),
+		Throttle:          throttle.New(cfg.BatchSize),
+		PostAgeBounds:     cfg.GetPostAgeBounds(),
+		KeepReactionEmoji: cfg.GetKeepReactionEmoji(),
+		DryRun:            dryRun,
+	}
+
+	if settings, err := cfg.GetPostRetentionJobSettings(); err == nil {
+		if deadline := settings.RunDeadline(time.Now()); !deadline.IsZero() {
+			var cancelWindow context.CancelFunc
+			ctx, cancelWindow = context.WithDeadlineCause(ctx, deadline, errWindowClosed)
+			defer cancelWindow()
+
+			p.API.LogDebug("Posts Retention run stops when the window closes", "deadline", deadline.Format(config.FullLayout))
+		}
+	}
+
+	if !opts.DryRun {
+		checkpoint, err := p.kvStore.GetRunCheckpoint()
+		if err != nil {
+			p.API.LogError("Cannot fetch Posts Retention checkpoint", "err", err)
+		} else if checkpoint != nil && checkpoint.Deferred {
+			p.API.LogInfo("Starting Posts Retention run with the users left over by the last run", "lastRunId", checkpoint.RunID, "phase", checkpoint.Phase)
+			opts.Resume = continueDeferred(checkpoint)
+		} else if checkpoint != nil {
+			p.API.LogInfo("Resuming interrupted Posts Retention run", "runId", checkpoint.RunID, "phase", checkpoint.Phase, "index", checkpoint.Index)
+			opts.Resume = checkpoint
+		}
+		if opts.Resume != nil {
+			opts.RunID = opts.Resume.RunID
+		}
+	}
+
+	startedAt := model.GetMillis()
+	status := &kvstore.RunStatus{
+		RunID:       opts.RunID,
+		Trigger:     string(trigger),
+		DryRun:      opts.DryRun,
+		NodeID:      nodeID(),
+		StartedAt:   startedAt,
+		HeartbeatAt: startedAt,
 	}
 
+	if !opts.DryRun {
+		postDeleter, err := p.newPostDeleter(cfg)
+		if err != nil {
+			p.API.LogError("Cannot create the Posts Retention deletion backend", "err", err)
+			p.delayResume()
+
+			status.FinishedAt = model.GetMillis()
+			status.ExitReason = string(ReasonError)
+			status.Errors = []string{fmt.Sprintf("cannot create the deletion backend: %s", err)}
+			p.saveRunStatus(status)
+			if err := p.kvStore.AddRunHistory(*status); err != nil {
+				p.API.LogError("Cannot save Posts Retention run history", "err", err)
+			}
+			return
+		}
+		// the throttle observes the backend itself, so time spent waiting on the rate limit is not taken for latency
+		opts.Deleter = deleter.NewRateLimited(opts.Throttle.Observe(postDeleter), cfg.MaxPostsPerSecond)
+	}
+	p.saveRunStatus(status)
+	stopHeartbeat := p.startHeartbeat(*status)
+
 	results, err := p.RemoveUserStalePosts(ctx, opts)
+	stopHeartbeat()
+	if results != nil && results.DryRunReport != nil {
+		p.saveDryRunReport(results.DryRunReport)
+	}
 	if err != nil {
-		p.API.LogError("Error running Posts Retention job", err)
+		p.API.LogError("Error running Posts Retention job", "err", err)
+	}
+
+	cause := context.Cause(ctx)
+	stoppedByPolicy := errors.Is(cause, errWindowClosed) || errors.Is(cause, errRunCancelled)
+	if results.ExitReason == ReasonCancelled && stoppedByPolicy {
+		results.ExitDetail = cause.Error()
+	}
+
+	// a run cancelled by a plugin stop keeps its checkpoint to be resumed right away; one stopped at the
+	// window end or by an admin is continued at the next scheduled run
+	if !opts.DryRun {
+		switch {
+		case results.ExitReason == ReasonCancelled && stoppedByPolicy:
+			p.deferCheckpoint(results)
+		case results.ExitReason != ReasonCancelled:
+			if err := p.kvStore.DeleteRunCheckpoint(); err != nil {
+				p.API.LogError("Cannot delete Posts Retention checkpoint", "err", err)
+			}
+		}
+	}
+
+	status.FinishedAt = model.GetMillis()
+	status.ExitReason = string(results.ExitReason)
+	status.ExitDetail = results.ExitDetail
+	status.PostsDeleted = results.PostsDeleted
+	status.PostsFailed = results.PostsFailed
+	status.PostsSkipped = results.PostsSkipped
+	if err != nil {
+		status.Errors = append(status.Errors, err.Error())
+	}
+	for _, failed := range results.FailedPosts {
+		status.Errors = append(status.Errors, fmt.Sprintf("post %s: %s", failed.PostID, failed.Error))
+	}
+	p.saveRunStatus(status)
+	if err := p.kvStore.AddRunHistory(*status); err != nil {
+		p.API.LogError("Cannot save Posts Retention run history", "err", err)
+	}
+
+	p.API.LogInfo("Posts Retention job", "runId", results.RunID, "trigger", trigger, "posts_deleted", results.PostsDeleted, "posts_failed", results.PostsFailed, "posts_skipped", results.PostsSkipped,
+		"users", len(results.PerUser), "status", results.ExitReason, "detail", results.ExitDetail, "duration", results.Duration.String())
+	for _, failed := range results.FailedPosts {
+		p.API.LogWarn("Posts Retention job failed to delete post", "postId", failed.PostID, "userId", failed.UserID, "error", failed.Error)
+	}
+}
+
+func (p *Plugin) saveRunStatus(status *kvstore.RunStatus) {
+	if err := p.kvStore.SetRunStatus(status); err != nil {
+		p.API.LogError("Cannot save Posts Retention run status", "err", err)
+	}
+}
+
+// startHeartbeat refreshes the status of the run in progress every kvstore.RunHeartbeatInterval until the
+// returned function is called, so a run whose node went down is not taken as running forever.
+func (p *Plugin) startHeartbeat(status kvstore.RunStatus) func() {
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(kvstore.RunHeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				status.HeartbeatAt = model.GetMillis()
+				p.saveRunStatus(&status)
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
+
+// nodeID identifies the cluster node the plugin runs on.
+func nodeID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return hostname
+}
+
+// deferCheckpoint marks the checkpoint of a run stopped at the window end or by an admin, so it is
+// continued at the next scheduled run instead of right away. The position of the stopped run is taken from
+// results, so it is saved even when no batch was deleted; the stored checkpoint is deferred when results is nil.
+func (p *Plugin) deferCheckpoint(results *ArchiverResults) {
+	var checkpoint *kvstore.RunCheckpoint
+	if results != nil {
+		checkpoint = results.stoppedCheckpoint()
+	}
+	if checkpoint == nil {
+		var err error
+		if checkpoint, err = p.kvStore.GetRunCheckpoint(); err != nil || checkpoint == nil {
+			return
+		}
+	}
+
+	checkpoint.Deferred = true
+	if err := p.kvStore.SetRunCheckpoint(checkpoint); err != nil {
+		p.API.LogError("Cannot save Posts Retention checkpoint", "err", err)
+	}
+}
+
+// delayResume postpones the resume of an interrupted run that failed to start, so the job does not retry
+// it in a loop. The delay doubles with each consecutive failure, up to maxResumeRetryInterval.
+func (p *Plugin) delayResume() {
+	checkpoint, err := p.kvStore.GetRunCheckpoint()
+	if err != nil || checkpoint == nil || checkpoint.Deferred {
+		return
+	}
+
+	checkpoint.FailedStarts++
+	delay := resumeRetryInterval(checkpoint.FailedStarts)
+	checkpoint.RetryAt = model.GetMillisForTime(time.Now().Add(delay))
+	if err := p.kvStore.SetRunCheckpoint(checkpoint); err != nil {
+		p.API.LogError("Cannot save Posts Retention checkpoint", "err", err)
 		return
 	}
+	p.API.LogInfo("Posts Retention will retry resuming the interrupted run", "runId", checkpoint.RunID, "wait", delay.String())
+}
 
-	p.API.LogInfo("Posts Retention job", "posts_deleted", results.PostsDeleted, "status", results.ExitReason, "duration", results.Duration.String())
+// resumeRetryInterval returns how long to wait before resuming a run after failedStarts consecutive failed starts.
+func resumeRetryInterval(failedStarts int) time.Duration {
+	delay := minResumeRetryInterval
+	for i := 1; i < failedStarts && delay < maxResumeRetryInterval; i++ {
+		delay *= 2
+	}
+	return min(delay, maxResumeRetryInterval)
+}
+
+// saveDryRunReport logs the report of a dry run and stores it for the admin API.
+func (p *Plugin) saveDryRunReport(report *kvstore.DryRunReport) {
+	report.FinishedAt = model.GetMillis()
+
+	p.API.LogInfo("Posts Retention dry run", "posts_to_delete", report.Total, "users", len(report.PostsByUser), "channels", len(report.PostsByChannel))
+	for userID, count := range report.PostsByUser {
+		p.API.LogInfo("Posts Retention dry run per user", "userId", userID, "posts_to_delete", count)
+	}
+	for channelID, count := range report.PostsByChannel {
+		p.API.LogInfo("Posts Retention dry run per channel", "channelId", channelID, "posts_to_delete", count)
+	}
+
+	if err := p.kvStore.SetDryRunReport(report); err != nil {
+		p.API.LogError("Cannot save Posts Retention dry run report", "err", err)
+	}
 }
 
 type PostRetentionJobHelper struct {
@@ -85,13 +356,18 @@ func (j *PostRetentionJobHelper) Start() error {
 	}
 
 	if settings.EnableRetentionPolicy {
-		job, err := cluster.Schedule(p.API, "posts_retention_policy_background_job", j.nextWaitInterval, p.runJob)
+		job, err := cluster.Schedule(p.API, retentionJobKey, j.nextWaitInterval, p.runJob)
 		if err != nil {
 			return fmt.Errorf("cannot start Posts Retention: %w", err)
 		}
 		p.backgroundJob = job
 
 		j.plugin.API.LogDebug("Posts Retention started", "dow", settings.DayOfWeek)
+
+		// an interrupted run is resumed right away, see nextWaitInterval
+		if checkpoint, err := p.kvStore.GetRunCheckpoint(); err == nil && checkpoint != nil && !checkpoint.Deferred {
+			j.plugin.API.LogInfo("Posts Retention will resume an interrupted run", "runId", checkpoint.RunID)
+		}
 	}
 
 	return nil
@@ -110,18 +386,19 @@ func (j *PostRetentionJobHelper) Stop(timeout time.Duration) error {
 
 	mErr := merror.New()
 
-	if job != nil {
-		if err := job.Close(); err != nil {
-			mErr.Append(fmt.Errorf("error closing job: %w", err))
-		}
-	}
-
+	// cancel the run first: closing the job waits for the running callback to return
 	if runner != nil {
 		if err := runner.stop(timeout); err != nil {
 			mErr.Append(fmt.Errorf("error stopping job runner: %w", err))
 		}
 	}
 
+	if job != nil {
+		if err := job.Close(); err != nil {
+			mErr.Append(fmt.Errorf("error closing job: %w", err))
+		}
+	}
+
 	j.plugin.API.LogDebug("Posts Retention stopped", "err", mErr.ErrorOrNil())
 
 	return mErr.ErrorOrNil()
@@ -133,9 +410,31 @@ func (j *PostRetentionJobHelper) nextWaitInterval(now time.Time, metadata cluste
 		lastFinished = now
 	}
 
-	settings, _ := j.plugin.getConfiguration().GetPostRetentionJobSettings()
+	cfg := j.plugin.getConfiguration()
+	settings, _ := cfg.GetPostRetentionJobSettings()
+	freezeUntil, err := j.plugin.freezeUntil()
+	if err != nil {
+		j.plugin.API.LogError("Cannot fetch Posts Retention freeze, checking again later", "err", err, "wait", freezeRetryInterval.String())
+		return freezeRetryInterval
+	}
+
+	if !cfg.DryRun {
+		if checkpoint, err := j.plugin.kvStore.GetRunCheckpoint(); err != nil {
+			j.plugin.API.LogError("Cannot fetch Posts Retention checkpoint", "err", err)
+		} else if checkpoint != nil && !checkpoint.Deferred {
+			// resumed once unfrozen and within the window, after the retry delay of a failed start
+			resumeAt := now
+			if retryAt := time.UnixMilli(checkpoint.RetryAt); retryAt.After(now) {
+				resumeAt = retryAt
+			}
+			resumeAt = settings.RunnableAt(resumeAt, freezeUntil)
+			j.plugin.API.LogDebug("Posts Retention resuming interrupted run", "runId", checkpoint.RunID, "at", resumeAt.Format(config.FullLayout))
+			return resumeAt.Sub(now)
+		}
+	}
 
-	next := settings.Frequency.CalcNext(lastFinished, settings.DayOfWeek, settings.TimeOfDay)
+	// runs in blackout dates or during a freeze are skipped
+	next := settings.NextUnfrozenRun(lastFinished, freezeUntil)
 	delta := next.Sub(now)
 	// Debug
 	//delta = (15 * time.Second) - now.Sub(metadata.LastFinished)
@@ -146,13 +445,17 @@ func (j *PostRetentionJobHelper) nextWaitInterval(now time.Time, metadata cluste
 }
 
 type runInstance struct {
-	canceller  func()        // called to stop a currently executing run
-	exitSignal chan struct{} // closed when the currently executing run has exited
+	canceller  context.CancelCauseFunc // called to stop a currently executing run
+	exitSignal chan struct{}           // closed when the currently executing run has exited
 }
 
 func (r *runInstance) stop(timeout time.Duration) error {
-	// cancel the run
-	r.canceller()
+	return r.cancel(context.Canceled, timeout)
+}
+
+// cancel stops the run, recording cause as the reason, and waits for it to exit.
+func (r *runInstance) cancel(cause error, timeout time.Duration) error {
+	r.canceller(cause)
 
 	// wait for it to exit
 	select {
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/config"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store/kvstore"
+)
+
+const (
+	// cancelRunEventID is the cluster event asking every node to cancel its retention run.
+	cancelRunEventID = "cancel_run"
+
+	// manualRunLockTimeout is how long a manual run waits for the job mutex, which the scheduled job
+	// also holds briefly to check whether it is due.
+	manualRunLockTimeout = 5 * time.Second
+	cancelRunTimeout     = 15 * time.Second
+)
+
+var errRunInProgress = errors.New("a retention run is already in progress")
+
+// RunNow starts a retention run in the background. It holds the job mutex for the whole run, so it
+// cannot overlap the scheduled job on any node.
+func (j *PostRetentionJobHelper) RunNow(dryRun bool) error {
+	j.mux.Lock()
+	running := j.runner != nil
+	j.mux.Unlock()
+	if running {
+		return errRunInProgress
+	}
+
+	// dry runs delete nothing, so they are allowed during a freeze
+	if settings, err := j.plugin.getConfiguration().GetPostRetentionJobSettings(); err == nil && !dryRun {
+		if until, why := j.plugin.checkFrozen(settings, time.Now()); !until.IsZero() {
+			return fmt.Errorf("%w until %s (%s)", errFrozen, until.Format(config.FullLayout), why)
+		}
+	}
+
+	mutex, err := cluster.NewMutex(j.plugin.API, retentionJobClusterKey)
+	if err != nil {
+		return fmt.Errorf("cannot create the job mutex: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), manualRunLockTimeout)
+	defer cancel()
+	if err := mutex.LockWithContext(ctx); err != nil {
+		return errRunInProgress
+	}
+
+	go func() {
+		defer mutex.Unlock()
+		j.plugin.runRetention(TriggerManual, dryRun)
+	}()
+
+	return nil
+}
+
+// CancelRun cancels the run executing on this node and asks the other nodes to cancel theirs. The
+// cancelled run is continued at the next scheduled run. It reports whether a run was in progress, on this
+// node or, according to the run status, on another one.
+func (j *PostRetentionJobHelper) CancelRun() (bool, error) {
+	j.mux.Lock()
+	running := j.runner != nil
+	j.mux.Unlock()
+
+	if !running {
+		status, err := j.plugin.kvStore.GetRunStatus()
+		if err != nil {
+			return false, err
+		}
+		// the status of a run whose node went down stays unfinished
+		if status == nil || !status.Running(model.GetMillis()) {
+			return false, nil
+		}
+	}
+
+	if err := j.plugin.API.PublishPluginClusterEvent(
+		model.PluginClusterEvent{Id: cancelRunEventID},
+		model.PluginClusterEventSendOptions{SendType: model.PluginClusterEventSendTypeReliable},
+	); err != nil {
+		return true, fmt.Errorf("cannot notify the other nodes: %w", err)
+	}
+
+	return true, j.cancelLocalRun()
+}
+
+// cancelLocalRun cancels the run executing on this node, if any.
+func (j *PostRetentionJobHelper) cancelLocalRun() error {
+	j.mux.Lock()
+	runner := j.runner
+	j.mux.Unlock()
+
+	if runner == nil {
+		return nil
+	}
+	return runner.cancel(errRunCancelled, cancelRunTimeout)
+}
+
+// NextRun returns when the scheduled job runs next, or the zero time when it is disabled.
+func (j *PostRetentionJobHelper) NextRun() (time.Time, error) {
+	runs, err := j.UpcomingRuns(1)
+	if err != nil || len(runs) == 0 {
+		return time.Time{}, err
+	}
+	return runs[0], nil
+}
+
+// UpcomingRuns returns the next count runs of the scheduled job in the time zone of the schedule, none when
+// it is disabled. The first run is computed by nextWaitInterval from the job metadata, the following ones
+// as if each run finished when it started.
+func (j *PostRetentionJobHelper) UpcomingRuns(count int) ([]time.Time, error) {
+	settings, err := j.plugin.getConfiguration().GetPostRetentionJobSettings()
+	if err != nil {
+		return nil, err
+	}
+	if !settings.EnableRetentionPolicy {
+		return nil, nil
+	}
+
+	var metadata cluster.JobMetadata
+	data, appErr := j.plugin.API.KVGet(retentionJobClusterKey)
+	if appErr != nil {
+		return nil, fmt.Errorf("cannot read the job metadata: %w", appErr)
+	}
+	if data != nil {
+		if err := json.Unmarshal(data, &metadata); err != nil {
+			return nil, fmt.Errorf("cannot decode the job metadata: %w", err)
+		}
+	}
+
+	now := time.Now()
+	wait := j.nextWaitInterval(now, metadata)
+	if wait < 0 {
+		wait = 0
+	}
+
+	loc := settings.TimeOfDay.Location()
+	freezeUntil, err := j.plugin.freezeUntil()
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]time.Time, 0, count)
+	for next := now.Add(wait); len(runs) < count && !next.IsZero(); {
+		runs = append(runs, next.In(loc))
+		next = settings.NextUnfrozenRun(next, freezeUntil)
+	}
+	return runs, nil
+}
+
+// RunNow starts a retention run on demand.
+func (p *Plugin) RunNow(dryRun bool) error {
+	return p.backgroundJobHelper.RunNow(dryRun)
+}
+
+// CancelRun cancels the retention run in progress, wherever it executes. It reports whether a run was in
+// progress.
+func (p *Plugin) CancelRun() (bool, error) {
+	return p.backgroundJobHelper.CancelRun()
+}
+
+// GetNextRun returns when the scheduled job runs next, or the zero time when it is disabled.
+func (p *Plugin) GetNextRun() (time.Time, error) {
+	return p.backgroundJobHelper.NextRun()
+}
+
+// GetUpcomingRuns returns the next count runs of the scheduled job in the time zone of the schedule, none
+// when it is disabled.
+func (p *Plugin) GetUpcomingRuns(count int) ([]time.Time, error) {
+	return p.backgroundJobHelper.UpcomingRuns(count)
+}
+
+// GetRunStatus returns the run in progress or the last run, nil when none ran yet. The counts of a run
+// that did not finish are taken from its last checkpoint.
+func (p *Plugin) GetRunStatus() (*kvstore.RunStatus, error) {
+	status, err := p.kvStore.GetRunStatus()
+	if err != nil || status == nil || status.FinishedAt != 0 {
+		return status, err
+	}
+
+	checkpoint, err := p.kvStore.GetRunCheckpoint()
+	if err == nil && checkpoint != nil && checkpoint.RunID == status.RunID {
+		status.PostsDeleted = checkpoint.PostsDeleted
+		status.PostsFailed = checkpoint.PostsFailed
+		status.PostsSkipped = checkpoint.PostsSkipped
+	}
+	return status, nil
+}
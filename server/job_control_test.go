@@ -0,0 +1,121 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/config"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store/kvstore"
+)
+
+// freezeKVStore serves the freeze, without a run checkpoint.
+type freezeKVStore struct {
+	kvstore.KVStore
+	freeze *kvstore.Freeze
+}
+
+func (s *freezeKVStore) GetFreeze() (*kvstore.Freeze, error) {
+	return s.freeze, nil
+}
+
+func (s *freezeKVStore) GetRunCheckpoint() (*kvstore.RunCheckpoint, error) {
+	return nil, nil
+}
+
+func TestUpcomingRuns(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	// the daily runs at 1:00am from the first one after now, which is the last finished run of a new job
+	now := time.Now().In(loc)
+	first := time.Date(now.Year(), now.Month(), now.Day(), 1, 0, 0, 0, loc)
+	if !first.After(now) {
+		first = first.AddDate(0, 0, 1)
+	}
+	day := func(n int) time.Time {
+		return first.AddDate(0, 0, n)
+	}
+	blackout := day(1).Format(config.BlackoutDateLayout) + ", " + day(3).Format(config.BlackoutDateLayout)
+
+	for _, tc := range []struct {
+		name          string
+		blackoutDates string
+		freeze        *kvstore.Freeze
+		count         int
+		expected      []time.Time
+	}{
+		{
+			name:     "daily",
+			count:    3,
+			expected: []time.Time{day(0), day(1), day(2)},
+		},
+		{
+			name:          "blackout dates",
+			blackoutDates: blackout,
+			count:         4,
+			expected:      []time.Time{day(0), day(2), day(4), day(5)},
+		},
+		{
+			name:     "freeze",
+			freeze:   &kvstore.Freeze{Until: day(1).Add(12 * time.Hour).UnixMilli()},
+			count:    2,
+			expected: []time.Time{day(2), day(3)},
+		},
+		{
+			name:          "freeze ending in blackout dates",
+			blackoutDates: blackout,
+			freeze:        &kvstore.Freeze{Until: day(1).Add(-12 * time.Hour).UnixMilli()},
+			count:         3,
+			expected:      []time.Time{day(2), day(4), day(5)},
+		},
+		{
+			name:     "past freeze",
+			freeze:   &kvstore.Freeze{Until: now.Add(-time.Hour).UnixMilli()},
+			count:    1,
+			expected: []time.Time{day(0)},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			api := &plugintest.API{}
+			api.On("KVGet", retentionJobClusterKey).Return(nil, (*model.AppError)(nil))
+			api.On("LogDebug", "Posts Retention next run scheduled", "last", mock.Anything, "next", mock.Anything, "wait", mock.Anything)
+			defer api.AssertExpectations(t)
+
+			p := &Plugin{kvStore: &freezeKVStore{freeze: tc.freeze}}
+			p.API = api
+			p.setConfiguration(&config.Configuration{
+				EnableRetentionPolicy: true,
+				Frequency:             string(config.Daily),
+				DayOfWeek:             "0",
+				TimeOfDay:             "1:00am",
+				TimeZone:              "Europe/Berlin",
+				BlackoutDates:         tc.blackoutDates,
+			})
+			p.backgroundJobHelper.plugin = p
+
+			runs, err := p.GetUpcomingRuns(tc.count)
+			require.NoError(t, err)
+			require.Len(t, runs, len(tc.expected))
+			for i, run := range runs {
+				assert.True(t, tc.expected[i].Equal(run), "run %d: want %s, got %s", i+1, tc.expected[i], run)
+				assert.Equal(t, "Europe/Berlin", run.Location().String())
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		p := &Plugin{}
+		p.setConfiguration(&config.Configuration{})
+		p.backgroundJobHelper.plugin = p
+
+		runs, err := p.GetUpcomingRuns(3)
+		assert.NoError(t, err)
+		assert.Empty(t, runs)
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store/kvstore"
+)
+
+func TestResumeRetryInterval(t *testing.T) {
+	for failedStarts, expected := range map[int]time.Duration{
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		3:  4 * time.Minute,
+		6:  32 * time.Minute,
+		7:  time.Hour,
+		50: time.Hour,
+	} {
+		assert.Equal(t, expected, resumeRetryInterval(failedStarts), "after %d failed starts", failedStarts)
+	}
+}
+
+// checkpointKVStore keeps the run checkpoint in memory.
+type checkpointKVStore struct {
+	kvstore.KVStore
+	checkpoint *kvstore.RunCheckpoint
+}
+
+func (s *checkpointKVStore) GetRunCheckpoint() (*kvstore.RunCheckpoint, error) {
+	return s.checkpoint, nil
+}
+
+func (s *checkpointKVStore) SetRunCheckpoint(checkpoint *kvstore.RunCheckpoint) error {
+	s.checkpoint = checkpoint
+	return nil
+}
+
+func TestDeferCheckpoint(t *testing.T) {
+	t.Run("no batch deleted", func(t *testing.T) {
+		store := &checkpointKVStore{}
+		p := &Plugin{kvStore: store}
+
+		// the window closed while the first users were processed, before any batch
+		results := newArchiverResults(nil)
+		results.enter(phaseUsers, 0, "user1")
+		p.deferCheckpoint(results)
+
+		if assert.NotNil(t, store.checkpoint) {
+			assert.True(t, store.checkpoint.Deferred)
+			assert.Equal(t, results.RunID, store.checkpoint.RunID)
+			assert.Equal(t, "user1", store.checkpoint.ScopeID)
+		}
+	})
+
+	t.Run("stored checkpoint", func(t *testing.T) {
+		store := &checkpointKVStore{checkpoint: &kvstore.RunCheckpoint{RunID: "run1", Phase: phaseChannels, ScopeID: "channel1"}}
+		p := &Plugin{kvStore: store}
+
+		p.deferCheckpoint(nil)
+		assert.Equal(t, &kvstore.RunCheckpoint{RunID: "run1", Phase: phaseChannels, ScopeID: "channel1", Deferred: true}, store.checkpoint)
+	})
+
+	t.Run("nothing to defer", func(t *testing.T) {
+		store := &checkpointKVStore{}
+		p := &Plugin{kvStore: store}
+
+		p.deferCheckpoint(newArchiverResults(nil))
+		assert.Nil(t, store.checkpoint)
+	})
+}
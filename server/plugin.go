@@ -8,7 +8,6 @@ import (
 	rbot "github.com/chaos-synthesis/mattermost-plugin-retention/server/bot"
 	"github.com/chaos-synthesis/mattermost-plugin-retention/server/command"
 	"github.com/chaos-synthesis/mattermost-plugin-retention/server/config"
-	"github.com/chaos-synthesis/mattermost-plugin-retention/server/mmctl/commands"
 	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store"
 	"github.com/chaos-synthesis/mattermost-plugin-retention/server/store/kvstore"
 	"github.com/gorilla/mux"
@@ -73,15 +72,6 @@ func (p *Plugin) OnActivate() error {
 	//}
 	//p.botUser = bot
 
-	p.commandClient = command.NewCommandHandler(p.client, p.kvStore)
-
-	// Create job for post retention
-	commands.PrepareRun()
-	p.backgroundJobHelper.plugin = p
-	if err := p.backgroundJobHelper.Start(); err != nil {
-		return errors.Wrap(err, "failed to schedule background job")
-	}
-
 	// Initialize SQL store
 	sqlStore, err := store.New(p.client.Store, &p.client.Log)
 	if err != nil {
@@ -89,6 +79,14 @@ func (p *Plugin) OnActivate() error {
 	}
 	p.sqlStore = sqlStore
 
+	p.commandClient = command.NewCommandHandler(p.client, p.kvStore, p)
+
+	// Create job for post retention
+	p.backgroundJobHelper.plugin = p
+	if err := p.backgroundJobHelper.Start(); err != nil {
+		return errors.Wrap(err, "failed to schedule background job")
+	}
+
 	return nil
 }
 
@@ -116,4 +114,31 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 	return response, nil
 }
 
+// OnPluginClusterEvent handles the events published by the plugin on other nodes.
+func (p *Plugin) OnPluginClusterEvent(_ *plugin.Context, ev model.PluginClusterEvent) {
+	if ev.Id == cancelRunEventID {
+		if err := p.backgroundJobHelper.cancelLocalRun(); err != nil {
+			p.API.LogError("Failed to cancel Posts Retention run", "err", err)
+		}
+	}
+}
+
+// GetKeepReaction reports the configured keep emoji and how many of the user's posts it protects.
+func (p *Plugin) GetKeepReaction(userID string) (command.KeepReaction, error) {
+	keepReaction := command.KeepReaction{
+		EmojiName: p.getConfiguration().GetKeepReactionEmoji(),
+	}
+	if keepReaction.EmojiName == "" {
+		return keepReaction, nil
+	}
+
+	count, err := p.sqlStore.CountKeptPosts(userID, keepReaction.EmojiName)
+	if err != nil {
+		return keepReaction, errors.Wrap(err, "failed to count kept posts")
+	}
+	keepReaction.Count = count
+
+	return keepReaction, nil
+}
+
 // See https://developers.mattermost.com/extend/plugins/server/reference/
@@ -0,0 +1,73 @@
+package store
+
+import (
+	sq "github.com/Masterminds/squirrel"
+)
+
+// AgeBasis is the timestamp a post's age is measured from.
+type AgeBasis string
+
+const (
+	// AgeBasisCreateAt measures the age from the post creation.
+	AgeBasisCreateAt AgeBasis = "create_at"
+	// AgeBasisUpdateAt measures the age from the last update of the post, which edits, reactions and pinning reset.
+	AgeBasisUpdateAt AgeBasis = "update_at"
+	// AgeBasisThreadLastReplyAt measures the age from the last reply in the post's thread, or its creation when it has no replies.
+	AgeBasisThreadLastReplyAt AgeBasis = "thread_last_reply_at"
+
+	DefaultAgeBasis = AgeBasisUpdateAt
+)
+
+// AgeBases lists the supported age bases in display order.
+var AgeBases = []AgeBasis{AgeBasisCreateAt, AgeBasisUpdateAt, AgeBasisThreadLastReplyAt}
+
+// AgeBasisFromString converts a stored age basis, falling back to DefaultAgeBasis for empty or unknown values.
+func AgeBasisFromString(s string) AgeBasis {
+	for _, basis := range AgeBases {
+		if string(basis) == s {
+			return basis
+		}
+	}
+	return DefaultAgeBasis
+}
+
+func (b AgeBasis) DisplayName() string {
+	switch b {
+	case AgeBasisCreateAt:
+		return "Creation time"
+	case AgeBasisThreadLastReplyAt:
+		return "Last thread activity"
+	default:
+		return "Last update"
+	}
+}
+
+// threadMemberColumn is the column of the other posts of a thread, aliased as alias, compared against the cutoff.
+// The last thread activity is the newest post creation in the thread.
+func (b AgeBasis) threadMemberColumn(alias string) string {
+	if b == AgeBasisUpdateAt {
+		return alias + ".UpdateAt"
+	}
+	return alias + ".CreateAt"
+}
+
+// stalePredicate selects posts p older than the cutoff according to the basis. The thread basis relies on
+// the Threads table joined as th by joinThreads.
+func (b AgeBasis) stalePredicate(olderThan int64) sq.Sqlizer {
+	switch b {
+	case AgeBasisCreateAt:
+		return sq.Lt{"p.CreateAt": olderThan}
+	case AgeBasisThreadLastReplyAt:
+		return sq.Expr("COALESCE(NULLIF(th.LastReplyAt, 0), p.CreateAt) < ?", olderThan)
+	default:
+		return sq.Lt{"p.UpdateAt": olderThan}
+	}
+}
+
+// joinThreads adds the join required by stalePredicate, if any.
+func (b AgeBasis) joinThreads(query sq.SelectBuilder) sq.SelectBuilder {
+	if b != AgeBasisThreadLastReplyAt {
+		return query
+	}
+	return query.LeftJoin("Threads as th ON th.PostId = (CASE WHEN p.RootId = '' THEN p.Id ELSE p.RootId END)")
+}
@@ -0,0 +1,47 @@
+package kvstore
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	activeChannelsKeyPrefix  = "rpp_active_channels"
+	channelSettingsKeyPrefix = "rpp_channel_settings-"
+)
+
+func (kv StoreImpl) GetChannelSettings(channelID string) (ChannelSettings, error) {
+	var channelSettings ChannelSettings
+	err := kv.client.KV.Get(channelSettingsKeyPrefix+channelID, &channelSettings)
+	if err != nil {
+		return ChannelSettings{}, errors.Wrap(err, "failed to get channel settings")
+	}
+	return channelSettings, nil
+}
+
+func (kv StoreImpl) SetChannelSettings(channelID string, value *ChannelSettings) error {
+	if value.Enabled {
+		_, err := kv.addToIDSet(activeChannelsKeyPrefix, channelID)
+		if err != nil {
+			return errors.Wrap(err, "failed to add active channel settings")
+		}
+	} else {
+		_, err := kv.removeFromIDSet(activeChannelsKeyPrefix, channelID)
+		if err != nil {
+			return errors.Wrap(err, "failed to remove active channel settings")
+		}
+	}
+
+	_, err := kv.client.KV.Set(channelSettingsKeyPrefix+channelID, value)
+	if err != nil {
+		return errors.Wrap(err, "failed to set channel settings")
+	}
+	return nil
+}
+
+func (kv StoreImpl) GetActiveChannels() ([]string, error) {
+	activeChannels, err := kv.getIDSet(activeChannelsKeyPrefix)
+	if err != nil {
+		return []string{}, errors.Wrap(err, "failed to get active channels")
+	}
+	return activeChannels, nil
+}
@@ -0,0 +1,32 @@
+package kvstore
+
+import (
+	"github.com/pkg/errors"
+)
+
+const runCheckpointKey = "rpp_run_checkpoint"
+
+func (kv StoreImpl) GetRunCheckpoint() (*RunCheckpoint, error) {
+	var checkpoint *RunCheckpoint
+	err := kv.client.KV.Get(runCheckpointKey, &checkpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get run checkpoint")
+	}
+	return checkpoint, nil
+}
+
+func (kv StoreImpl) SetRunCheckpoint(checkpoint *RunCheckpoint) error {
+	_, err := kv.client.KV.Set(runCheckpointKey, checkpoint)
+	if err != nil {
+		return errors.Wrap(err, "failed to set run checkpoint")
+	}
+	return nil
+}
+
+func (kv StoreImpl) DeleteRunCheckpoint() error {
+	err := kv.client.KV.Delete(runCheckpointKey)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete run checkpoint")
+	}
+	return nil
+}
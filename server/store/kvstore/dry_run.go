@@ -0,0 +1,24 @@
+package kvstore
+
+import (
+	"github.com/pkg/errors"
+)
+
+const dryRunReportKey = "rpp_dry_run_report"
+
+func (kv StoreImpl) GetDryRunReport() (*DryRunReport, error) {
+	var report *DryRunReport
+	err := kv.client.KV.Get(dryRunReportKey, &report)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get dry run report")
+	}
+	return report, nil
+}
+
+func (kv StoreImpl) SetDryRunReport(report *DryRunReport) error {
+	_, err := kv.client.KV.Set(dryRunReportKey, report)
+	if err != nil {
+		return errors.Wrap(err, "failed to set dry run report")
+	}
+	return nil
+}
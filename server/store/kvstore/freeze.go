@@ -0,0 +1,32 @@
+package kvstore
+
+import (
+	"github.com/pkg/errors"
+)
+
+const freezeKey = "rpp_freeze"
+
+func (kv StoreImpl) GetFreeze() (*Freeze, error) {
+	var freeze *Freeze
+	err := kv.client.KV.Get(freezeKey, &freeze)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get freeze")
+	}
+	return freeze, nil
+}
+
+func (kv StoreImpl) SetFreeze(freeze *Freeze) error {
+	_, err := kv.client.KV.Set(freezeKey, freeze)
+	if err != nil {
+		return errors.Wrap(err, "failed to set freeze")
+	}
+	return nil
+}
+
+func (kv StoreImpl) DeleteFreeze() error {
+	err := kv.client.KV.Delete(freezeKey)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete freeze")
+	}
+	return nil
+}
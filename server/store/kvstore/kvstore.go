@@ -1,11 +1,193 @@
 package kvstore
 
-import "github.com/mattermost/mattermost/server/public/model"
+import (
+	"slices"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
 
 type UserSettings struct {
 	UserID        string
 	Enabled       bool
 	PostAgeInDays float64
+	// PostAgeInHours is a retention period in hours, for short-lived posts; when set it takes precedence over PostAgeInDays.
+	PostAgeInHours float64
+	// DeletePinned opts in to deleting pinned posts, which are kept by default.
+	DeletePinned bool
+	// DeleteSaved opts in to deleting saved (flagged) posts, which are kept by default.
+	DeleteSaved bool
+	// ThreadMode is one of the store.ThreadMode values; empty means the default mode.
+	ThreadMode string
+	// AgeBasis is one of the store.AgeBasis values; empty means the default basis.
+	AgeBasis string
+	// ChannelTypes limits the policy to posts in channels of these types; empty means all types.
+	ChannelTypes []model.ChannelType
+	// ExcludedChannelIDs are channels where the user's posts are never purged by the user or team policies.
+	ExcludedChannelIDs []string
+}
+
+// RetentionInDays returns the retention period in days, from PostAgeInHours when it is set.
+func (s UserSettings) RetentionInDays() float64 {
+	if s.PostAgeInHours > 0 {
+		return s.PostAgeInHours / 24
+	}
+	return s.PostAgeInDays
+}
+
+// IncludesChannelType reports whether the policy applies to posts in channels of the given type.
+func (s UserSettings) IncludesChannelType(channelType model.ChannelType) bool {
+	return len(s.ChannelTypes) == 0 || slices.Contains(s.ChannelTypes, channelType)
+}
+
+// ChannelSettings is a retention policy applied to every post in a channel, set by a channel admin.
+type ChannelSettings struct {
+	ChannelID     string
+	Enabled       bool
+	PostAgeInDays float64
+	// ThreadMode is one of the store.ThreadMode values; empty means the default mode.
+	ThreadMode string
+	// AgeBasis is one of the store.AgeBasis values; empty means the default basis.
+	AgeBasis string
+	// UpdatedBy is the ID of the user that last changed the policy.
+	UpdatedBy string
+}
+
+// TeamSettings is a default retention policy for the posts of every team member in the team's channels,
+// set by a team admin. A member's own UserSettings win when they are stricter.
+type TeamSettings struct {
+	TeamID        string
+	Enabled       bool
+	PostAgeInDays float64
+	// ThreadMode is one of the store.ThreadMode values; empty means the default mode.
+	ThreadMode string
+	// AgeBasis is one of the store.AgeBasis values; empty means the default basis.
+	AgeBasis string
+	// UpdatedBy is the ID of the user that last changed the policy.
+	UpdatedBy string
+}
+
+// DryRunReport lists the posts a dry retention run would have deleted.
+type DryRunReport struct {
+	StartedAt  int64
+	FinishedAt int64
+	// Total is the number of posts that would have been deleted.
+	Total int
+	// PostsByUser counts the posts that would have been deleted per author.
+	PostsByUser map[string]int
+	// PostsByChannel counts the posts that would have been deleted per channel.
+	PostsByChannel map[string]int
+
+	// reported holds the IDs of the posts already counted: a real run deletes a post in the first pass
+	// whose policy matches it, while a dry run finds it again in the channel and team passes.
+	reported map[string]struct{}
+}
+
+func NewDryRunReport() *DryRunReport {
+	return &DryRunReport{
+		StartedAt:      model.GetMillis(),
+		PostsByUser:    map[string]int{},
+		PostsByChannel: map[string]int{},
+		reported:       map[string]struct{}{},
+	}
+}
+
+// Add records posts that would have been deleted, once per post.
+func (r *DryRunReport) Add(posts []*model.Post) {
+	for _, post := range posts {
+		if _, ok := r.reported[post.Id]; ok {
+			continue
+		}
+		r.reported[post.Id] = struct{}{}
+
+		r.Total++
+		r.PostsByUser[post.UserId]++
+		r.PostsByChannel[post.ChannelId]++
+	}
+}
+
+// RunCheckpoint is the position and running totals of a retention run, saved after every batch so an
+// interrupted run can be resumed where it stopped.
+type RunCheckpoint struct {
+	RunID     string
+	StartedAt int64
+	UpdatedAt int64
+	// Phase is the kind of policies being applied: users, channels or teams.
+	Phase string
+	// Index is the position in the active users, channels or teams of the phase, and ScopeID the ID found there.
+	Index   int
+	ScopeID string
+	// MemberIndex is the position in the team members during the teams phase.
+	MemberIndex int
+	// Kind is the post kind pass in progress, replies or roots.
+	Kind string
+	// CursorCreateAt and CursorPostID are the keyset cursor after the last processed batch.
+	CursorCreateAt int64
+	CursorPostID   string
+	PostsDeleted   int
+	PostsFailed    int
+	PostsSkipped   int
+	// UsersFrom is the user the active users are rotated to start at, so the users left over by a run
+	// stopped at the window end or by an admin go first.
+	UsersFrom string
+	// Deferred is set when the run was stopped at the window end or by an admin; it then continues at the
+	// next scheduled run instead of right away.
+	Deferred bool
+	// FailedStarts counts the consecutive resumes that failed to start, and RetryAt is when the next one is
+	// attempted, in milliseconds.
+	FailedStarts int
+	RetryAt      int64
+}
+
+// RunStatus describes the retention run in progress, or the last one when FinishedAt is set.
+type RunStatus struct {
+	RunID string
+	// Trigger is what started the run: scheduled or manual.
+	Trigger string
+	DryRun  bool
+	// NodeID identifies the cluster node the run executes on.
+	NodeID     string
+	StartedAt  int64
+	FinishedAt int64
+	// HeartbeatAt is when the node last reported the run in progress, every RunHeartbeatInterval.
+	HeartbeatAt int64
+	ExitReason  string
+	ExitDetail  string
+	// PostsDeleted, PostsFailed and PostsSkipped are set when the run finished.
+	PostsDeleted int
+	PostsFailed  int
+	PostsSkipped int
+	// Errors holds the error that stopped the run and the errors of the first failed posts.
+	Errors []string
+}
+
+// Running reports whether the run has not finished yet and its node still reported it at now, in milliseconds.
+func (s *RunStatus) Running(now int64) bool {
+	return s.FinishedAt == 0 && !s.stale(now)
+}
+
+// Interrupted reports whether the run never finished because its node stopped, such as on a crash.
+func (s *RunStatus) Interrupted(now int64) bool {
+	return s.FinishedAt == 0 && s.stale(now)
+}
+
+func (s *RunStatus) stale(now int64) bool {
+	heartbeatAt := max(s.HeartbeatAt, s.StartedAt)
+	return now-heartbeatAt > runStaleAfter.Milliseconds()
+}
+
+// Freeze pauses the retention job until a time, without disabling it, during audits and incident investigations.
+type Freeze struct {
+	// Until is when the freeze ends, in milliseconds.
+	Until int64
+	// SetBy is the ID of the admin that set the freeze.
+	SetBy  string
+	SetAt  int64
+	Reason string
+}
+
+// Active reports whether the freeze has not ended at now, in milliseconds.
+func (f *Freeze) Active(now int64) bool {
+	return f != nil && now < f.Until
 }
 
 // KVStore Define your methods here. This package is used to access the KVStore pluginapi methods.
@@ -17,4 +199,40 @@ type KVStore interface {
 	SetUserSettings(userID string, value *UserSettings) error
 
 	GetActiveUsers() ([]string, error)
+
+	GetChannelSettings(channelID string) (ChannelSettings, error)
+
+	SetChannelSettings(channelID string, value *ChannelSettings) error
+
+	GetActiveChannels() ([]string, error)
+
+	GetTeamSettings(teamID string) (TeamSettings, error)
+
+	SetTeamSettings(teamID string, value *TeamSettings) error
+
+	GetActiveTeams() ([]string, error)
+
+	GetDryRunReport() (*DryRunReport, error)
+
+	SetDryRunReport(report *DryRunReport) error
+
+	GetRunCheckpoint() (*RunCheckpoint, error)
+
+	SetRunCheckpoint(checkpoint *RunCheckpoint) error
+
+	DeleteRunCheckpoint() error
+
+	GetRunStatus() (*RunStatus, error)
+
+	SetRunStatus(status *RunStatus) error
+
+	GetRunHistory() ([]RunStatus, error)
+
+	AddRunHistory(status RunStatus) error
+
+	GetFreeze() (*Freeze, error)
+
+	SetFreeze(freeze *Freeze) error
+
+	DeleteFreeze() error
 }
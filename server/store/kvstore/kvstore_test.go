@@ -0,0 +1,50 @@
+package kvstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRunReportAdd(t *testing.T) {
+	report := NewDryRunReport()
+
+	// the user pass finds the user's posts, then the channel pass finds all of the channel's posts
+	report.Add([]*model.Post{
+		{Id: "post1", UserId: "user1", ChannelId: "channel1"},
+		{Id: "post2", UserId: "user1", ChannelId: "channel2"},
+	})
+	report.Add([]*model.Post{
+		{Id: "post1", UserId: "user1", ChannelId: "channel1"},
+		{Id: "post3", UserId: "user2", ChannelId: "channel1"},
+	})
+
+	assert.Equal(t, 3, report.Total)
+	assert.Equal(t, map[string]int{"user1": 2, "user2": 1}, report.PostsByUser)
+	assert.Equal(t, map[string]int{"channel1": 2, "channel2": 1}, report.PostsByChannel)
+}
+
+func TestRunStatusRunning(t *testing.T) {
+	now := model.GetMillis()
+	minute := time.Minute.Milliseconds()
+
+	for _, tc := range []struct {
+		name        string
+		status      RunStatus
+		running     bool
+		interrupted bool
+	}{
+		{name: "just started", status: RunStatus{StartedAt: now, HeartbeatAt: now}, running: true},
+		{name: "recent heartbeat", status: RunStatus{StartedAt: now - 60*minute, HeartbeatAt: now - minute}, running: true},
+		{name: "stale heartbeat", status: RunStatus{StartedAt: now - 60*minute, HeartbeatAt: now - 10*minute}, interrupted: true},
+		{name: "no heartbeat", status: RunStatus{StartedAt: now - 10*minute}, interrupted: true},
+		{name: "finished", status: RunStatus{StartedAt: now - 60*minute, HeartbeatAt: now - 10*minute, FinishedAt: now - 10*minute}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.running, tc.status.Running(now))
+			assert.Equal(t, tc.interrupted, tc.status.Interrupted(now))
+		})
+	}
+}
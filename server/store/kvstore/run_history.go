@@ -0,0 +1,42 @@
+package kvstore
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	runHistoryKey = "rpp_run_history"
+
+	// MaxRunHistory is the number of runs kept in the history.
+	MaxRunHistory = 100
+)
+
+// GetRunHistory returns the finished runs, newest first.
+func (kv StoreImpl) GetRunHistory() ([]RunStatus, error) {
+	var history []RunStatus
+	err := kv.client.KV.Get(runHistoryKey, &history)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get run history")
+	}
+	return history, nil
+}
+
+// AddRunHistory records a finished run, dropping the oldest runs beyond MaxRunHistory. Runs are
+// serialized by the job mutex, so no concurrent update is expected.
+func (kv StoreImpl) AddRunHistory(status RunStatus) error {
+	history, err := kv.GetRunHistory()
+	if err != nil {
+		return err
+	}
+
+	history = append([]RunStatus{status}, history...)
+	if len(history) > MaxRunHistory {
+		history = history[:MaxRunHistory]
+	}
+
+	_, err = kv.client.KV.Set(runHistoryKey, history)
+	if err != nil {
+		return errors.Wrap(err, "failed to set run history")
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package kvstore
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const runStatusKey = "rpp_run_status"
+
+const (
+	// RunHeartbeatInterval is how often the node executing a run refreshes its status.
+	RunHeartbeatInterval = time.Minute
+	// runStaleAfter is how long after the last heartbeat a run that never finished is taken as interrupted.
+	runStaleAfter = 5 * RunHeartbeatInterval
+)
+
+func (kv StoreImpl) GetRunStatus() (*RunStatus, error) {
+	var status *RunStatus
+	err := kv.client.KV.Get(runStatusKey, &status)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get run status")
+	}
+	return status, nil
+}
+
+func (kv StoreImpl) SetRunStatus(status *RunStatus) error {
+	_, err := kv.client.KV.Set(runStatusKey, status)
+	if err != nil {
+		return errors.Wrap(err, "failed to set run status")
+	}
+	return nil
+}
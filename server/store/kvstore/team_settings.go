@@ -0,0 +1,47 @@
+package kvstore
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	activeTeamsKeyPrefix  = "rpp_active_teams"
+	teamSettingsKeyPrefix = "rpp_team_settings-"
+)
+
+func (kv StoreImpl) GetTeamSettings(teamID string) (TeamSettings, error) {
+	var teamSettings TeamSettings
+	err := kv.client.KV.Get(teamSettingsKeyPrefix+teamID, &teamSettings)
+	if err != nil {
+		return TeamSettings{}, errors.Wrap(err, "failed to get team settings")
+	}
+	return teamSettings, nil
+}
+
+func (kv StoreImpl) SetTeamSettings(teamID string, value *TeamSettings) error {
+	if value.Enabled {
+		_, err := kv.addToIDSet(activeTeamsKeyPrefix, teamID)
+		if err != nil {
+			return errors.Wrap(err, "failed to add active team settings")
+		}
+	} else {
+		_, err := kv.removeFromIDSet(activeTeamsKeyPrefix, teamID)
+		if err != nil {
+			return errors.Wrap(err, "failed to remove active team settings")
+		}
+	}
+
+	_, err := kv.client.KV.Set(teamSettingsKeyPrefix+teamID, value)
+	if err != nil {
+		return errors.Wrap(err, "failed to set team settings")
+	}
+	return nil
+}
+
+func (kv StoreImpl) GetActiveTeams() ([]string, error) {
+	activeTeams, err := kv.getIDSet(activeTeamsKeyPrefix)
+	if err != nil {
+		return []string{}, errors.Wrap(err, "failed to get active teams")
+	}
+	return activeTeams, nil
+}
@@ -78,8 +78,7 @@ func (kv StoreImpl) SetUserSettings(userID string, value *UserSettings) error {
 }
 
 func (kv StoreImpl) GetActiveUsers() ([]string, error) {
-	var activeUsers []string
-	err := kv.client.KV.Get(activeUsersKeyPrefix, &activeUsers)
+	activeUsers, err := kv.getIDSet(activeUsersKeyPrefix)
 	if err != nil {
 		return []string{}, errors.Wrap(err, "failed to get active users")
 	}
@@ -87,29 +86,47 @@ func (kv StoreImpl) GetActiveUsers() ([]string, error) {
 }
 
 func (kv StoreImpl) addActiveUser(userID string) (bool, error) {
-	activeUsers, err := kv.GetActiveUsers()
+	return kv.addToIDSet(activeUsersKeyPrefix, userID)
+}
+
+func (kv StoreImpl) removeActiveUser(userID string) (bool, error) {
+	return kv.removeFromIDSet(activeUsersKeyPrefix, userID)
+}
+
+// getIDSet reads a list of IDs stored under the given key.
+func (kv StoreImpl) getIDSet(key string) ([]string, error) {
+	var ids []string
+	err := kv.client.KV.Get(key, &ids)
+	if err != nil {
+		return []string{}, err
+	}
+	return ids, nil
+}
+
+func (kv StoreImpl) addToIDSet(key string, id string) (bool, error) {
+	ids, err := kv.getIDSet(key)
 	if err != nil {
 		return false, err
 	}
-	if slices.Contains(activeUsers, userID) {
+	if slices.Contains(ids, id) {
 		return false, nil
 	}
 
-	activeUsers = append(activeUsers, userID)
+	ids = append(ids, id)
 
-	return kv.client.KV.Set(activeUsersKeyPrefix, activeUsers)
+	return kv.client.KV.Set(key, ids)
 }
 
-func (kv StoreImpl) removeActiveUser(userID string) (bool, error) {
-	activeUsers, err := kv.GetActiveUsers()
+func (kv StoreImpl) removeFromIDSet(key string, id string) (bool, error) {
+	ids, err := kv.getIDSet(key)
 	if err != nil {
 		return false, err
 	}
-	if !slices.Contains(activeUsers, userID) {
+	if !slices.Contains(ids, id) {
 		return false, nil
 	}
 
-	idx := slices.Index(activeUsers, userID)
-	activeUsers = slices.Delete(activeUsers, idx, idx+1)
-	return kv.client.KV.Set(activeUsersKeyPrefix, activeUsers)
+	idx := slices.Index(ids, id)
+	ids = slices.Delete(ids, idx, idx+1)
+	return kv.client.KV.Set(key, ids)
 }
@@ -5,30 +5,107 @@ import (
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
 )
 
+var ErrNoStalePostScope = errors.New("stale post selection requires a user, a channel or a team")
+
 type StalePostOpts struct {
 	AgeInDays float64
-	UserId    string
+	// AgeBasis is the timestamp the age is measured from, DefaultAgeBasis when empty.
+	AgeBasis AgeBasis
+	// UserId limits the selection to posts authored by this user.
+	UserId string
+	// ChannelId limits the selection to posts in this channel.
+	ChannelId string
+	// TeamId limits the selection to posts in the channels of this team.
+	TeamId string
+	// ChannelTypes limits the selection to posts in channels of these types; empty means all types.
+	ChannelTypes []model.ChannelType
+	// ExcludedChannelIds skips posts in these channels.
+	ExcludedChannelIds []string
+	// ExcludePinned skips posts pinned to their channel.
+	ExcludePinned bool
+	// ExcludeFlagged skips posts saved (flagged) by any user.
+	ExcludeFlagged bool
+	// KeepReactionEmoji skips posts carrying this reaction from their author or an admin; empty disables the check.
+	KeepReactionEmoji string
+	// ThreadMode controls which posts of a thread may be deleted, DefaultThreadMode when empty.
+	ThreadMode ThreadMode
+	// Kind restricts the selection to root posts or replies.
+	Kind PostKind
+}
+
+// PostCursor is a keyset position in the (CreateAt, Id) order of posts. The zero value points before the first post.
+type PostCursor struct {
+	CreateAt int64
+	PostId   string
+}
+
+// IsZero reports whether the cursor points before the first post.
+func (c PostCursor) IsZero() bool {
+	return c.CreateAt == 0 && c.PostId == ""
 }
 
-func (ss *SQLStore) GetStalePosts(opts StalePostOpts, page int, pageSize int) ([]string, bool, error) {
+// GetStalePosts returns the page of posts matching opts that follows the cursor, and the cursor to pass
+// to fetch the next page. Only the Id, UserId, ChannelId, RootId and CreateAt fields of the posts are populated.
+func (ss *SQLStore) GetStalePosts(opts StalePostOpts, cursor PostCursor, pageSize int) ([]*model.Post, PostCursor, bool, error) {
+	if opts.UserId == "" && opts.ChannelId == "" && opts.TeamId == "" {
+		return nil, cursor, false, ErrNoStalePostScope
+	}
+
 	olderThan := model.GetMillisForTime(time.Now().Add(-1 * time.Duration(opts.AgeInDays*24.*float64(time.Hour))))
+	basis := AgeBasisFromString(string(opts.AgeBasis))
 
-	// find all channels where no posts or reactions have been modified,deleted since the olderThan timestamp.
-	query := ss.builder.Select("p.Id").Distinct().
-		From("Posts as p").
-		Where(sq.And{
-			sq.Eq{"p.UserId": opts.UserId},
-			sq.Lt{"p.UpdateAt": olderThan},
-			sq.Eq{"p.DeleteAt": 0},
-		}).
-		GroupBy("p.Id").
-		OrderBy("p.Id")
+	// find all posts that are older than the olderThan timestamp according to the age basis.
+	conditions := sq.And{
+		basis.stalePredicate(olderThan),
+		sq.Eq{"p.DeleteAt": 0},
+	}
+	if opts.UserId != "" {
+		conditions = append(conditions, sq.Eq{"p.UserId": opts.UserId})
+	}
+	if opts.ChannelId != "" {
+		conditions = append(conditions, sq.Eq{"p.ChannelId": opts.ChannelId})
+	}
+	if len(opts.ExcludedChannelIds) > 0 {
+		conditions = append(conditions, sq.NotEq{"p.ChannelId": opts.ExcludedChannelIds})
+	}
+	if opts.ExcludePinned {
+		conditions = append(conditions, sq.Eq{"p.IsPinned": false})
+	}
+	if opts.ExcludeFlagged {
+		conditions = append(conditions, sq.Expr("NOT EXISTS ("+flaggedSubquery("p")+")", model.PreferenceCategoryFlaggedPost))
+	}
+	if opts.KeepReactionEmoji != "" {
+		conditions = append(conditions, sq.Expr("NOT EXISTS ("+keepReactionSubquery("p")+")", opts.KeepReactionEmoji, "%"+model.SystemAdminRoleId+"%"))
+	}
+	conditions = append(conditions, threadConditions(opts, olderThan)...)
+	if !cursor.IsZero() {
+		conditions = append(conditions, sq.Or{
+			sq.Gt{"p.CreateAt": cursor.CreateAt},
+			sq.And{sq.Eq{"p.CreateAt": cursor.CreateAt}, sq.Gt{"p.Id": cursor.PostId}},
+		})
+	}
+
+	// every join is one-to-one, so no post is selected twice
+	query := ss.builder.Select("p.Id", "p.UserId", "p.ChannelId", "p.RootId", "p.CreateAt").
+		From("Posts as p")
+	query = basis.joinThreads(query)
 
-	if page > 0 {
-		query = query.Offset(uint64(page) * uint64(pageSize)) //nolint:gosec // page and pageSize are validated to be non-negative
+	if opts.TeamId != "" || len(opts.ChannelTypes) > 0 {
+		query = query.Join("Channels as c ON c.Id = p.ChannelId")
 	}
+	if opts.TeamId != "" {
+		conditions = append(conditions, sq.Eq{"c.TeamId": opts.TeamId})
+	}
+	if len(opts.ChannelTypes) > 0 {
+		conditions = append(conditions, sq.Eq{"c.Type": opts.ChannelTypes})
+	}
+
+	query = query.
+		Where(conditions).
+		OrderBy("p.CreateAt", "p.Id")
 
 	if pageSize > 0 {
 		// N+1 to check if there's a next page for pagination
@@ -38,18 +115,23 @@ func (ss *SQLStore) GetStalePosts(opts StalePostOpts, page int, pageSize int) ([
 	rows, err := query.Query()
 	if err != nil {
 		ss.logger.Error("error fetching stale posts", "err", err)
-		return nil, false, err
+		return nil, cursor, false, err
 	}
+	defer rows.Close()
 
-	posts := []string{}
+	posts := []*model.Post{}
 	for rows.Next() {
 		post := &model.Post{}
 
-		if err := rows.Scan(&post.Id); err != nil {
+		if err := rows.Scan(&post.Id, &post.UserId, &post.ChannelId, &post.RootId, &post.CreateAt); err != nil {
 			ss.logger.Error("error scanning stale posts", "err", err)
-			return nil, false, err
+			return nil, cursor, false, err
 		}
-		posts = append(posts, post.Id)
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		ss.logger.Error("error fetching stale posts", "err", err)
+		return nil, cursor, false, err
 	}
 
 	var hasMore bool
@@ -58,5 +140,45 @@ func (ss *SQLStore) GetStalePosts(opts StalePostOpts, page int, pageSize int) ([
 		posts = posts[0:pageSize]
 	}
 
-	return posts, hasMore, nil
+	if len(posts) > 0 {
+		last := posts[len(posts)-1]
+		cursor = PostCursor{CreateAt: last.CreateAt, PostId: last.Id}
+	}
+
+	return posts, cursor, hasMore, nil
+}
+
+// flaggedSubquery matches the saved (flagged) preferences of the post aliased as post. It takes the flagged
+// post preference category as argument.
+func flaggedSubquery(post string) string {
+	return "SELECT 1 FROM Preferences AS pr WHERE pr.Category = ? AND pr.Name = " + post + ".Id"
+}
+
+// keepReactionSubquery matches the keep reactions of the post aliased as post that were added by its author, a
+// system admin or an admin of its channel. It takes the emoji name and a LIKE pattern of the system admin role
+// as arguments.
+func keepReactionSubquery(post string) string {
+	return "SELECT 1 FROM Reactions AS re " +
+		"LEFT JOIN Users AS ru ON ru.Id = re.UserId " +
+		"LEFT JOIN ChannelMembers AS cm ON cm.ChannelId = " + post + ".ChannelId AND cm.UserId = re.UserId " +
+		"WHERE re.PostId = " + post + ".Id AND re.EmojiName = ? AND re.DeleteAt = 0 " +
+		"AND (re.UserId = " + post + ".UserId OR ru.Roles LIKE ? OR cm.SchemeAdmin = true)"
+}
+
+// CountKeptPosts returns the number of the user's posts protected by the keep reaction.
+func (ss *SQLStore) CountKeptPosts(userId string, keepReactionEmoji string) (int, error) {
+	query := ss.builder.Select("COUNT(p.Id)").
+		From("Posts as p").
+		Where(sq.And{
+			sq.Eq{"p.UserId": userId},
+			sq.Eq{"p.DeleteAt": 0},
+			sq.Expr("EXISTS ("+keepReactionSubquery("p")+")", keepReactionEmoji, "%"+model.SystemAdminRoleId+"%"),
+		})
+
+	var count int
+	if err := query.QueryRow().Scan(&count); err != nil {
+		ss.logger.Error("error counting kept posts", "err", err)
+		return 0, err
+	}
+	return count, nil
 }
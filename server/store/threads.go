@@ -0,0 +1,107 @@
+package store
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// ThreadMode controls how a policy treats posts that are part of a thread.
+type ThreadMode string
+
+const (
+	// ThreadModeKeepRoots keeps root posts that have replies from other users or replies that are not stale yet.
+	ThreadModeKeepRoots ThreadMode = "keep_roots"
+	// ThreadModeWholeThread deletes a post only when every post of its thread is stale.
+	ThreadModeWholeThread ThreadMode = "whole_thread"
+	// ThreadModeRepliesOnly deletes replies only; root posts are always kept.
+	ThreadModeRepliesOnly ThreadMode = "replies_only"
+
+	DefaultThreadMode = ThreadModeKeepRoots
+)
+
+// ThreadModes lists the supported thread modes in display order.
+var ThreadModes = []ThreadMode{ThreadModeKeepRoots, ThreadModeWholeThread, ThreadModeRepliesOnly}
+
+// ThreadModeFromString converts a stored thread mode, falling back to DefaultThreadMode for empty or unknown values.
+func ThreadModeFromString(s string) ThreadMode {
+	for _, mode := range ThreadModes {
+		if string(mode) == s {
+			return mode
+		}
+	}
+	return DefaultThreadMode
+}
+
+func (m ThreadMode) DisplayName() string {
+	switch m {
+	case ThreadModeWholeThread:
+		return "Delete whole threads once all posts are stale"
+	case ThreadModeRepliesOnly:
+		return "Delete replies only"
+	default:
+		return "Keep roots with replies from others"
+	}
+}
+
+// PostKind restricts a stale post selection to root posts or replies.
+type PostKind string
+
+const (
+	PostKindAny   PostKind = ""
+	PostKindReply PostKind = "reply"
+	PostKindRoot  PostKind = "root"
+)
+
+// threadConditions builds the predicates implementing the thread mode and post kind of opts.
+// olderThan is the staleness cutoff used for the other posts of the thread. Deleting a root deletes its
+// replies, so a reply that is pinned, saved or kept by reaction blocks its thread like a recent one.
+func threadConditions(opts StalePostOpts, olderThan int64) sq.And {
+	conditions := sq.And{}
+	basis := AgeBasisFromString(string(opts.AgeBasis))
+
+	switch opts.Kind {
+	case PostKindReply:
+		conditions = append(conditions, sq.NotEq{"p.RootId": ""})
+	case PostKindRoot:
+		conditions = append(conditions, sq.Eq{"p.RootId": ""})
+	}
+
+	switch ThreadModeFromString(string(opts.ThreadMode)) {
+	case ThreadModeRepliesOnly:
+		conditions = append(conditions, sq.NotEq{"p.RootId": ""})
+	case ThreadModeWholeThread:
+		blockers := append(sq.Or{sq.Expr(basis.threadMemberColumn("t")+" >= ?", olderThan)}, protectedConditions(opts, "t")...)
+		conditions = append(conditions, sq.Expr(
+			"NOT EXISTS (SELECT 1 FROM Posts AS t WHERE t.DeleteAt = 0 AND ? AND "+
+				"(t.RootId = p.Id OR (p.RootId <> '' AND (t.Id = p.RootId OR t.RootId = p.RootId))))",
+			blockers,
+		))
+	default:
+		blockers := append(sq.Or{
+			sq.Expr("r.UserId <> p.UserId"),
+			sq.Expr(basis.threadMemberColumn("r")+" >= ?", olderThan),
+		}, protectedConditions(opts, "r")...)
+		conditions = append(conditions, sq.Or{
+			sq.NotEq{"p.RootId": ""},
+			sq.Expr("NOT EXISTS (SELECT 1 FROM Posts AS r WHERE r.RootId = p.Id AND r.DeleteAt = 0 AND ?)", blockers),
+		})
+	}
+
+	return conditions
+}
+
+// protectedConditions matches the post aliased as post when opts keeps it regardless of its age: pinned,
+// saved or carrying the keep reaction.
+func protectedConditions(opts StalePostOpts, post string) sq.Or {
+	protected := sq.Or{}
+	if opts.ExcludePinned {
+		protected = append(protected, sq.Eq{post + ".IsPinned": true})
+	}
+	if opts.ExcludeFlagged {
+		protected = append(protected, sq.Expr("EXISTS ("+flaggedSubquery(post)+")", model.PreferenceCategoryFlaggedPost))
+	}
+	if opts.KeepReactionEmoji != "" {
+		protected = append(protected, sq.Expr("EXISTS ("+keepReactionSubquery(post)+")", opts.KeepReactionEmoji, "%"+model.SystemAdminRoleId+"%"))
+	}
+	return protected
+}
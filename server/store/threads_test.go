@@ -0,0 +1,82 @@
+package store
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThreadConditionsKeepRootsWithProtectedReplies(t *testing.T) {
+	const olderThan = int64(1000)
+
+	for _, tc := range []struct {
+		name  string
+		mode  ThreadMode
+		alias string
+		args  []any
+	}{
+		{"keep roots", ThreadModeKeepRoots, "r", []any{"", olderThan}},
+		{"whole thread", ThreadModeWholeThread, "t", []any{olderThan}},
+	} {
+		alias := tc.alias
+
+		for _, protection := range []struct {
+			name      string
+			opts      StalePostOpts
+			predicate string
+			args      []any
+		}{
+			{
+				name:      "pinned",
+				opts:      StalePostOpts{ExcludePinned: true},
+				predicate: alias + ".IsPinned = ?",
+				args:      []any{true},
+			},
+			{
+				name:      "saved",
+				opts:      StalePostOpts{ExcludeFlagged: true},
+				predicate: "EXISTS (" + flaggedSubquery(alias) + ")",
+				args:      []any{model.PreferenceCategoryFlaggedPost},
+			},
+			{
+				name:      "keep reaction",
+				opts:      StalePostOpts{KeepReactionEmoji: "pushpin"},
+				predicate: "EXISTS (" + keepReactionSubquery(alias) + ")",
+				args:      []any{"pushpin", "%" + model.SystemAdminRoleId + "%"},
+			},
+		} {
+			t.Run(tc.name+"/"+protection.name, func(t *testing.T) {
+				opts := protection.opts
+				opts.ThreadMode = tc.mode
+
+				sql, args, err := threadConditions(opts, olderThan).ToSql()
+				require.NoError(t, err)
+
+				// the reply blocks the root from the subquery matching the other posts of the thread
+				start := strings.Index(sql, "NOT EXISTS (SELECT 1 FROM Posts AS "+alias+" ")
+				require.NotEqual(t, -1, start, sql)
+				assert.Contains(t, sql[start:], " OR "+protection.predicate, sql)
+				assert.Equal(t, append(append([]any{}, tc.args...), protection.args...), args)
+			})
+		}
+
+		t.Run(tc.name+"/unprotected", func(t *testing.T) {
+			sql, args, err := threadConditions(StalePostOpts{ThreadMode: tc.mode}, olderThan).ToSql()
+			require.NoError(t, err)
+			assert.NotContains(t, sql, alias+".IsPinned")
+			assert.NotContains(t, sql, "Preferences")
+			assert.NotContains(t, sql, "Reactions")
+			assert.Equal(t, tc.args, args)
+		})
+	}
+
+	t.Run("replies only", func(t *testing.T) {
+		sql, args, err := threadConditions(StalePostOpts{ThreadMode: ThreadModeRepliesOnly, ExcludePinned: true}, olderThan).ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, "(p.RootId <> ?)", sql)
+		assert.Equal(t, []any{""}, args)
+	})
+}
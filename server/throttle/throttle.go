@@ -0,0 +1,150 @@
+// Package throttle adapts the pace of the retention job to the load of the database and the deletion backend.
+package throttle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/config"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/deleter"
+)
+
+const (
+	// InitialDelay is the pause between batches before any latency was measured.
+	InitialDelay = 5 * time.Second
+	MinDelay     = 500 * time.Millisecond
+	MaxDelay     = 2 * time.Minute
+
+	// targetFetchLatency and targetDeleteLatency are the latencies above which the controller slows down;
+	// below half of them it speeds up.
+	targetFetchLatency  = 500 * time.Millisecond
+	targetDeleteLatency = 100 * time.Millisecond
+
+	// errorSpikeRatio is the share of failed operations above which the controller backs off to the minimum batch size.
+	errorSpikeRatio = 0.2
+)
+
+// Controller sizes the batches of stale posts and the delay between them. It is shared by all workers of
+// a run, so it reacts to their combined load. Latencies and errors are collected between calls to Adjust.
+type Controller struct {
+	mux       sync.Mutex
+	batchSize int
+	delay     time.Duration
+
+	fetches      int
+	fetchLatency time.Duration
+	deletes      int
+	deleteTime   time.Duration
+	errors       int
+}
+
+// New returns a controller starting at batchSize, clamped to the config batch size bounds.
+func New(batchSize int) *Controller {
+	return &Controller{
+		batchSize: clampBatchSize(batchSize),
+		delay:     InitialDelay,
+	}
+}
+
+// BatchSize is the number of posts to fetch and delete in the next batch.
+func (c *Controller) BatchSize() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.batchSize
+}
+
+// ObserveFetch records the latency of a stale posts query.
+func (c *Controller) ObserveFetch(latency time.Duration, err error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.fetches++
+	c.fetchLatency += latency
+	if err != nil {
+		c.errors++
+	}
+}
+
+// ObserveDelete records the latency of a delete call and the outcome of its posts. Posts skipped because
+// the run was cancelled are not failures.
+func (c *Controller) ObserveDelete(latency time.Duration, results []deleter.Result) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	for _, result := range results {
+		if errors.Is(result.Err, context.Canceled) || errors.Is(result.Err, context.DeadlineExceeded) {
+			continue
+		}
+		c.deletes++
+		if result.Err != nil {
+			c.errors++
+		}
+	}
+	c.deleteTime += latency
+}
+
+// Observe wraps a PostDeleter so every delete call is observed.
+func (c *Controller) Observe(inner deleter.PostDeleter) deleter.PostDeleter {
+	return &observedDeleter{inner: inner, controller: c}
+}
+
+// Adjust resizes the batch and the delay from the operations observed since the last call and returns
+// the delay to wait before the next batch. Errors shrink the batch and stretch the delay, sharply when
+// they spike; slow operations shrink it gently, fast ones grow it step by step.
+func (c *Controller) Adjust() time.Duration {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	operations := c.fetches + c.deletes
+	if operations == 0 {
+		return c.delay
+	}
+
+	errorRatio := float64(c.errors) / float64(operations)
+	var fetchLatency, deleteLatency time.Duration
+	if c.fetches > 0 {
+		fetchLatency = c.fetchLatency / time.Duration(c.fetches)
+	}
+	if c.deletes > 0 {
+		deleteLatency = c.deleteTime / time.Duration(c.deletes)
+	}
+
+	switch {
+	case errorRatio >= errorSpikeRatio:
+		c.batchSize = config.MinBatchSize
+		c.delay = max(c.delay*4, InitialDelay)
+	case errorRatio > 0:
+		c.batchSize /= 2
+		c.delay *= 2
+	case fetchLatency > targetFetchLatency || deleteLatency > targetDeleteLatency:
+		c.batchSize = c.batchSize * 3 / 4
+		c.delay = c.delay * 3 / 2
+	case fetchLatency < targetFetchLatency/2 && deleteLatency < targetDeleteLatency/2:
+		c.batchSize += config.MinBatchSize
+		c.delay = c.delay * 9 / 10
+	}
+	c.batchSize = clampBatchSize(c.batchSize)
+	c.delay = min(max(c.delay, MinDelay), MaxDelay)
+
+	c.fetches, c.fetchLatency, c.deletes, c.deleteTime, c.errors = 0, 0, 0, 0, 0
+	return c.delay
+}
+
+func clampBatchSize(batchSize int) int {
+	return min(max(batchSize, config.MinBatchSize), config.MaxBatchSize)
+}
+
+// observedDeleter reports the latency of the wrapped deleter to a Controller.
+type observedDeleter struct {
+	inner      deleter.PostDeleter
+	controller *Controller
+}
+
+func (d *observedDeleter) DeletePosts(ctx context.Context, postIDs []string) []deleter.Result {
+	start := time.Now()
+	results := d.inner.DeletePosts(ctx, postIDs)
+	d.controller.ObserveDelete(time.Since(start), results)
+	return results
+}
@@ -0,0 +1,64 @@
+package throttle
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/config"
+	"github.com/chaos-synthesis/mattermost-plugin-retention/server/deleter"
+)
+
+func deleteResults(ok, failed int) []deleter.Result {
+	results := make([]deleter.Result, 0, ok+failed)
+	for i := 0; i < ok; i++ {
+		results = append(results, deleter.Result{})
+	}
+	for i := 0; i < failed; i++ {
+		results = append(results, deleter.Result{Err: errors.New("boom")})
+	}
+	return results
+}
+
+func TestController(t *testing.T) {
+	tests := []struct {
+		name          string
+		fetchLatency  time.Duration
+		deleteLatency time.Duration
+		ok, failed    int
+		wantBatchSize int
+		wantDelay     time.Duration
+	}{
+		{name: "fast grows", fetchLatency: 10 * time.Millisecond, deleteLatency: 100 * time.Millisecond, ok: 10, wantBatchSize: 110, wantDelay: 4500 * time.Millisecond},
+		{name: "steady keeps", fetchLatency: 300 * time.Millisecond, deleteLatency: 500 * time.Millisecond, ok: 10, wantBatchSize: 100, wantDelay: InitialDelay},
+		{name: "slow query shrinks", fetchLatency: 2 * time.Second, deleteLatency: 100 * time.Millisecond, ok: 10, wantBatchSize: 75, wantDelay: 7500 * time.Millisecond},
+		{name: "slow deletes shrink", fetchLatency: 10 * time.Millisecond, deleteLatency: 2 * time.Second, ok: 10, wantBatchSize: 75, wantDelay: 7500 * time.Millisecond},
+		{name: "some errors halve", fetchLatency: 10 * time.Millisecond, deleteLatency: 100 * time.Millisecond, ok: 19, failed: 1, wantBatchSize: 50, wantDelay: 10 * time.Second},
+		{name: "error spike backs off sharply", fetchLatency: 10 * time.Millisecond, deleteLatency: 100 * time.Millisecond, ok: 5, failed: 5, wantBatchSize: config.MinBatchSize, wantDelay: 20 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(100)
+			c.ObserveFetch(tt.fetchLatency, nil)
+			c.ObserveDelete(tt.deleteLatency, deleteResults(tt.ok, tt.failed))
+
+			assert.Equal(t, tt.wantDelay, c.Adjust())
+			assert.Equal(t, tt.wantBatchSize, c.BatchSize())
+		})
+	}
+
+	t.Run("stays within bounds", func(t *testing.T) {
+		c := New(5000)
+		assert.Equal(t, config.MaxBatchSize, c.BatchSize())
+
+		for i := 0; i < 20; i++ {
+			c.ObserveFetch(time.Second, errors.New("timeout"))
+			c.Adjust()
+		}
+		assert.Equal(t, config.MinBatchSize, c.BatchSize())
+		assert.Equal(t, MaxDelay, c.Adjust())
+	})
+}